@@ -0,0 +1,68 @@
+package anvil
+
+import (
+	"fmt"
+	"log"
+	"net"
+	"time"
+
+	"github.com/ethereum-optimism/presigner/pkg/cast"
+	"github.com/ethereum-optimism/presigner/pkg/shell"
+)
+
+// DefaultSender is the first prefunded and unlocked anvil dev account.
+const DefaultSender = "0xf39Fd6e51aad88F6F4ce6aB8827279cffFb92266"
+
+type Node struct {
+	process shell.Process
+	port    string
+}
+
+// Start spins up an anvil node forking forkUrl and waits until it serves chainId,
+// so that the fork is not confused with another node listening on port.
+func Start(r shell.Runner, forkUrl, port, chainId string) (*Node, error) {
+	// anvil exits if the port is taken, but another node would answer the readiness check first
+	listener, err := net.Listen("tcp", "127.0.0.1:"+port)
+	if err != nil {
+		return nil, fmt.Errorf("port %s is not available for anvil: %w", port, err)
+	}
+	listener.Close()
+
+	log.Printf("starting anvil fork on port %s\n", port)
+	process, err := r.Start("anvil",
+		"--fork-url", forkUrl,
+		"--port", port,
+		"--silent")
+	if err != nil {
+		return nil, err
+	}
+	node := &Node{process: process, port: port}
+
+	deadline := time.Now().Add(30 * time.Second)
+	for time.Now().Before(deadline) {
+		select {
+		case <-process.Exited():
+			return nil, fmt.Errorf("anvil exited before it was ready: %v", process.Err())
+		case <-time.After(250 * time.Millisecond):
+		}
+		id, err := cast.ChainId(r, node.URL())
+		if err != nil {
+			continue
+		}
+		if id != chainId {
+			node.Stop()
+			return nil, fmt.Errorf("node on port %s serves chain %s, expected a fork of chain %s", port, id, chainId)
+		}
+		return node, nil
+	}
+	node.Stop()
+	return nil, fmt.Errorf("anvil did not start serving on port %s", port)
+}
+
+func (n *Node) URL() string {
+	return "http://127.0.0.1:" + n.port
+}
+
+func (n *Node) Stop() {
+	n.process.Stop()
+}
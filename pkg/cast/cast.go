@@ -0,0 +1,194 @@
+package cast
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"strings"
+
+	"github.com/ethereum-optimism/presigner/pkg/shell"
+)
+
+type Log struct {
+	Address         string   `json:"address"`
+	Topics          []string `json:"topics"`
+	Data            string   `json:"data"`
+	BlockNumber     string   `json:"blockNumber,omitempty"`
+	LogIndex        string   `json:"logIndex,omitempty"`
+	TransactionHash string   `json:"transactionHash,omitempty"`
+}
+
+type Receipt struct {
+	TransactionHash string `json:"transactionHash"`
+	From            string `json:"from"`
+	Status          string `json:"status"`
+	Logs            []Log  `json:"logs"`
+}
+
+type AccountState struct {
+	Balance string            `json:"balance,omitempty"`
+	Nonce   uint64            `json:"nonce,omitempty"`
+	Storage map[string]string `json:"storage,omitempty"`
+}
+
+// StateDiff is the result of the prestateTracer in diffMode.
+type StateDiff struct {
+	Pre  map[string]AccountState `json:"pre"`
+	Post map[string]AccountState `json:"post"`
+}
+
+// Call runs `cast call` and returns the trimmed output.
+func Call(r shell.Runner, rpcUrl, to, sig string, args ...string) (string, error) {
+	callArgs := []string{"call", to, sig}
+	callArgs = append(callArgs, args...)
+	callArgs = append(callArgs, "--rpc-url", rpcUrl)
+	outBuffer, _, err := r.Run("cast", []string{}, "", true, callArgs...)
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(string(outBuffer)), nil
+}
+
+// IsRevert returns true if err is the failure of a call that reverted, rather than e.g. of the RPC.
+func IsRevert(err error) bool {
+	exitErr, ok := shell.IsExitError(err)
+	return ok && strings.Contains(strings.ToLower(string(exitErr.Stderr)), "revert")
+}
+
+// Logs returns the logs emitted by address from fromBlock to the latest block,
+// with topic0 if it is set.
+func Logs(r shell.Runner, rpcUrl, address, fromBlock, topic0 string) ([]Log, error) {
+	args := []string{
+		"logs",
+		"--rpc-url", rpcUrl,
+		"--address", address,
+		"--from-block", fromBlock,
+		"--to-block", "latest",
+		"--json"}
+	if topic0 != "" {
+		args = append(args, topic0)
+	}
+	outBuffer, _, err := r.Run("cast", []string{}, "", true, args...)
+	if err != nil {
+		return nil, err
+	}
+	var logs []Log
+	if err := json.Unmarshal(outBuffer, &logs); err != nil {
+		return nil, fmt.Errorf("invalid logs from cast: %w", err)
+	}
+	return logs, nil
+}
+
+// ChainId returns the chain id of the node at rpcUrl.
+func ChainId(r shell.Runner, rpcUrl string) (string, error) {
+	outBuffer, _, err := r.Run("cast", []string{}, "", true,
+		"chain-id",
+		"--rpc-url", rpcUrl)
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(string(outBuffer)), nil
+}
+
+// Before returns true if l was emitted before other, by block number then log index.
+func (l *Log) Before(other *Log) bool {
+	block, otherBlock := parseQuantity(l.BlockNumber), parseQuantity(other.BlockNumber)
+	if c := block.Cmp(otherBlock); c != 0 {
+		return c < 0
+	}
+	return parseQuantity(l.LogIndex).Cmp(parseQuantity(other.LogIndex)) < 0
+}
+
+// parseQuantity reads a hex or decimal number, as printed by cast, invalid numbers are zero.
+func parseQuantity(s string) *big.Int {
+	n, ok := new(big.Int).SetString(s, 0)
+	if !ok {
+		return new(big.Int)
+	}
+	return n
+}
+
+// Code returns the deployed bytecode at address, 0x if there is none.
+func Code(r shell.Runner, rpcUrl, address string) (string, error) {
+	outBuffer, _, err := r.Run("cast", []string{}, "", true,
+		"code",
+		address,
+		"--rpc-url", rpcUrl)
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(string(outBuffer)), nil
+}
+
+// SendUnlocked sends calldata to `to` from an account unlocked in the node,
+// e.g. a dev account of a local anvil fork.
+func SendUnlocked(r shell.Runner, rpcUrl, from, to, calldata string) (*Receipt, error) {
+	outBuffer, _, err := r.Run("cast", []string{}, "", true,
+		"send",
+		"--rpc-url", rpcUrl,
+		"--unlocked",
+		"--from", from,
+		"--json",
+		to,
+		calldata)
+	if err != nil {
+		return nil, err
+	}
+	var receipt Receipt
+	if err := json.Unmarshal(outBuffer, &receipt); err != nil {
+		return nil, fmt.Errorf("invalid receipt from cast: %w", err)
+	}
+	return &receipt, nil
+}
+
+// Send signs and sends a call of sig to `to`, signingFlags select the wallet, e.g. --private-key.
+func Send(r shell.Runner, rpcUrl string, signingFlags []string, to, sig string, args ...string) (*Receipt, error) {
+	sendArgs := []string{"send", "--rpc-url", rpcUrl, "--json"}
+	sendArgs = append(sendArgs, signingFlags...)
+	sendArgs = append(sendArgs, to, sig)
+	sendArgs = append(sendArgs, args...)
+	outBuffer, _, err := r.Run("cast", []string{}, "", true, sendArgs...)
+	if err != nil {
+		return nil, err
+	}
+	var receipt Receipt
+	if err := json.Unmarshal(outBuffer, &receipt); err != nil {
+		return nil, fmt.Errorf("invalid receipt from cast: %w", err)
+	}
+	return &receipt, nil
+}
+
+// TraceStateDiff returns the storage and balance changes of a mined transaction.
+func TraceStateDiff(r shell.Runner, rpcUrl, txHash string) (*StateDiff, error) {
+	outBuffer, _, err := r.Run("cast", []string{}, "", true,
+		"rpc",
+		"--rpc-url", rpcUrl,
+		"debug_traceTransaction",
+		txHash,
+		`{"tracer":"prestateTracer","tracerConfig":{"diffMode":true}}`)
+	if err != nil {
+		return nil, err
+	}
+	var diff StateDiff
+	if err := json.Unmarshal(outBuffer, &diff); err != nil {
+		return nil, fmt.Errorf("invalid trace from cast: %w", err)
+	}
+	return &diff, nil
+}
+
+// DecodeString decodes ABI encoded data holding a single string.
+func DecodeString(data string) (string, error) {
+	b, err := hex.DecodeString(strings.TrimPrefix(data, "0x"))
+	if err != nil {
+		return "", err
+	}
+	if len(b) < 64 {
+		return "", fmt.Errorf("data too short")
+	}
+	length := new(big.Int).SetBytes(b[32:64])
+	if !length.IsInt64() || int64(len(b)-64) < length.Int64() {
+		return "", fmt.Errorf("data too short")
+	}
+	return string(b[64 : 64+length.Int64()]), nil
+}
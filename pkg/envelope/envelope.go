@@ -0,0 +1,95 @@
+package envelope
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+
+	"github.com/ethereum-optimism/presigner/pkg/shell"
+)
+
+// Headers of files encrypted by age, armored or binary.
+const (
+	armorHeader  = "-----BEGIN AGE ENCRYPTED FILE-----"
+	binaryHeader = "age-encryption.org/v1"
+)
+
+// ErrPlaintext is returned by Seal when encryption is required but not enabled.
+var ErrPlaintext = errors.New("refusing to write in plaintext contents read from an encrypted file")
+
+// Envelope encrypts files at rest with the age CLI, to recipients or with a passphrase.
+// A nil Envelope leaves contents in plaintext. age runs on the terminal to prompt for the passphrase.
+type Envelope struct {
+	Runner     shell.Runner
+	Recipients []string
+
+	// prompt for a passphrase on the terminal instead of encrypting to recipients
+	Passphrase bool
+
+	// identity file used to decrypt, not needed for passphrase encrypted files
+	Identity string
+
+	// refuse to seal contents in plaintext, set once contents of an encrypted file were read
+	RequireEncryption bool
+}
+
+// Enabled returns true if new files are encrypted.
+func (e *Envelope) Enabled() bool {
+	return e != nil && (len(e.Recipients) > 0 || e.Passphrase)
+}
+
+// IsEncrypted returns true if contents were encrypted by age.
+func IsEncrypted(contents []byte) bool {
+	trimmed := bytes.TrimSpace(contents)
+	return bytes.HasPrefix(trimmed, []byte(armorHeader)) || bytes.HasPrefix(trimmed, []byte(binaryHeader))
+}
+
+// Seal encrypts contents, armored so they can be stored as text,
+// contents are returned unchanged if encryption is not enabled or they are already encrypted.
+func (e *Envelope) Seal(contents []byte) ([]byte, error) {
+	if IsEncrypted(contents) {
+		return contents, nil
+	}
+	if !e.Enabled() {
+		if e != nil && e.RequireEncryption {
+			return nil, ErrPlaintext
+		}
+		return contents, nil
+	}
+	args := []string{"--encrypt", "--armor"}
+	if e.Passphrase {
+		args = append(args, "--passphrase")
+	}
+	for _, recipient := range e.Recipients {
+		args = append(args, "--recipient", recipient)
+	}
+	outBuffer, _, err := e.runner().RunTerminal("age", []string{}, string(contents), true, args...)
+	if err != nil {
+		return nil, fmt.Errorf("error encrypting: %w", err)
+	}
+	return outBuffer, nil
+}
+
+// Open decrypts contents encrypted by Seal, plaintext contents are returned unchanged.
+func (e *Envelope) Open(contents []byte) ([]byte, error) {
+	if !IsEncrypted(contents) {
+		return contents, nil
+	}
+	args := []string{"--decrypt"}
+	if e != nil && e.Identity != "" {
+		args = append(args, "--identity", e.Identity)
+	}
+	outBuffer, _, err := e.runner().RunTerminal("age", []string{}, string(contents), true, args...)
+	if err != nil {
+		return nil, fmt.Errorf("error decrypting: %w", err)
+	}
+	return outBuffer, nil
+}
+
+// runner returns the Runner of the envelope, a nil Envelope still opens encrypted files in the current directory.
+func (e *Envelope) runner() shell.Runner {
+	if e == nil || e.Runner == nil {
+		return &shell.Exec{Workdir: "."}
+	}
+	return e.Runner
+}
@@ -0,0 +1,91 @@
+package envelope
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/ethereum-optimism/presigner/pkg/shell"
+)
+
+const armored = "-----BEGIN AGE ENCRYPTED FILE-----\nYWdlLWVuY3J5cHRpb24ub3JnL3Yx\n-----END AGE ENCRYPTED FILE-----\n"
+
+func TestSeal(t *testing.T) {
+	recipient := "age1ql3z7hjy54pw3hyww5ayyfg7zqgvc7w3j2elw8zmrj2kg5sfn9aqmcac8p"
+	tests := []struct {
+		name     string
+		envelope *Envelope
+		contents string
+		want     string
+		wantErr  error
+	}{
+		{
+			name:     "nil",
+			contents: "{}",
+			want:     "{}",
+		},
+		{
+			name:     "not enabled",
+			envelope: &Envelope{},
+			contents: "{}",
+			want:     "{}",
+		},
+		{
+			name:     "encryption required",
+			envelope: &Envelope{RequireEncryption: true},
+			contents: "{}",
+			wantErr:  ErrPlaintext,
+		},
+		{
+			name:     "already encrypted",
+			envelope: &Envelope{RequireEncryption: true},
+			contents: armored,
+			want:     armored,
+		},
+		{
+			name: "recipients",
+			envelope: &Envelope{
+				Runner: &shell.Replayer{Calls: []shell.Call{{
+					Name:   "age",
+					Args:   []string{"--encrypt", "--armor", "--recipient", recipient},
+					Stdin:  "{}",
+					Stdout: armored,
+				}}},
+				Recipients:        []string{recipient},
+				RequireEncryption: true,
+			},
+			contents: "{}",
+			want:     armored,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.envelope.Seal([]byte(tt.contents))
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("expected error %v, got %v", tt.wantErr, err)
+			}
+			if string(got) != tt.want {
+				t.Fatalf("expected %q, got %q", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestOpen(t *testing.T) {
+	e := &Envelope{
+		Runner: &shell.Replayer{Calls: []shell.Call{{
+			Name:   "age",
+			Args:   []string{"--decrypt", "--identity", "key.txt"},
+			Stdin:  armored,
+			Stdout: "{}",
+		}}},
+		Identity: "key.txt",
+	}
+	plaintext, err := e.Open([]byte("{}"))
+	if err != nil || string(plaintext) != "{}" {
+		t.Fatalf("plaintext not returned unchanged: %q, %v", plaintext, err)
+	}
+	decrypted, err := e.Open([]byte(armored))
+	if err != nil || string(decrypted) != "{}" {
+		t.Fatalf("unexpected decrypted contents: %q, %v", decrypted, err)
+	}
+}
@@ -0,0 +1,342 @@
+package multicall
+
+import (
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"os"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+// Call is a single IMulticall3.Call3 of a transaction.
+type Call struct {
+	Target       string   `json:"target"`
+	Signature    string   `json:"signature"`
+	Args         []string `json:"args,omitempty"`
+	AllowFailure bool     `json:"allow_failure,omitempty"`
+
+	// populated by Encode
+	Calldata string `json:"calldata,omitempty"`
+}
+
+// ParseSpec parses a call given as "target:signature[:arg...]", e.g. "0x1234...:pause(string):presigner".
+// Arguments containing ':' are double quoted, e.g. `"https://example.com"`, arrays are kept whole.
+func ParseSpec(spec string) (Call, error) {
+	target, rest, ok := strings.Cut(spec, ":")
+	if !ok {
+		return Call{}, fmt.Errorf("invalid call %q, expected target:signature[:arg...]", spec)
+	}
+	signature, rest, hasArgs := strings.Cut(rest, ":")
+	if signature == "" {
+		return Call{}, fmt.Errorf("invalid call %q, expected target:signature[:arg...]", spec)
+	}
+	call := Call{
+		Target:    target,
+		Signature: signature,
+	}
+	if hasArgs {
+		args, err := splitList(rest, ':')
+		if err != nil {
+			return Call{}, fmt.Errorf("invalid call %q: %w", spec, err)
+		}
+		for _, arg := range args {
+			call.Args = append(call.Args, unquote(arg))
+		}
+	}
+	return call, call.validate()
+}
+
+// ReadFile reads a JSON array of calls.
+func ReadFile(file string) ([]Call, error) {
+	contents, err := os.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	var calls []Call
+	if err := json.Unmarshal(contents, &calls); err != nil {
+		return nil, err
+	}
+	for _, call := range calls {
+		if err := call.validate(); err != nil {
+			return nil, err
+		}
+	}
+	return calls, nil
+}
+
+func (c Call) validate() error {
+	if !common.IsHexAddress(c.Target) {
+		return fmt.Errorf("invalid call target: %s", c.Target)
+	}
+	_, types, err := parseSignature(c.Signature)
+	if err != nil {
+		return err
+	}
+	if len(types) != len(c.Args) {
+		return fmt.Errorf("%s takes %d arguments, got %d, quote arguments containing ':'", c.Signature, len(types), len(c.Args))
+	}
+	return nil
+}
+
+// Encode computes the calldata of every call from its signature and arguments.
+func Encode(calls []Call) error {
+	for i := range calls {
+		calldata, err := calls[i].encode()
+		if err != nil {
+			return fmt.Errorf("encoding %s: %w", calls[i].Signature, err)
+		}
+		calls[i].Calldata = hexutil.Encode(calldata)
+	}
+	return nil
+}
+
+func (c Call) encode() ([]byte, error) {
+	name, types, err := parseSignature(c.Signature)
+	if err != nil {
+		return nil, err
+	}
+	if len(types) != len(c.Args) {
+		return nil, fmt.Errorf("expected %d arguments, got %d", len(types), len(c.Args))
+	}
+	arguments := make(abi.Arguments, 0, len(types))
+	values := make([]interface{}, 0, len(types))
+	canonical := make([]string, 0, len(types))
+	for i, typ := range types {
+		value, err := parseValue(typ, c.Args[i])
+		if err != nil {
+			return nil, fmt.Errorf("argument %d: %w", i, err)
+		}
+		arguments = append(arguments, abi.Argument{Type: typ})
+		values = append(values, value)
+		canonical = append(canonical, typ.String())
+	}
+	packed, err := arguments.Pack(values...)
+	if err != nil {
+		return nil, err
+	}
+	selector := crypto.Keccak256([]byte(name + "(" + strings.Join(canonical, ",") + ")"))[:4]
+	return append(selector, packed...), nil
+}
+
+// parseSignature splits a function signature, e.g. "pause(string)", into its name and
+// parameter types, tuples are not supported.
+func parseSignature(signature string) (string, []abi.Type, error) {
+	name, params, ok := strings.Cut(signature, "(")
+	if !ok || name == "" || !strings.HasSuffix(params, ")") {
+		return "", nil, fmt.Errorf("invalid call signature: %s", signature)
+	}
+	params = strings.TrimSuffix(params, ")")
+	if strings.ContainsAny(params, "()") {
+		return "", nil, fmt.Errorf("unsupported call signature %s, tuples cannot be encoded", signature)
+	}
+	var types []abi.Type
+	if strings.TrimSpace(params) == "" {
+		return name, types, nil
+	}
+	for _, param := range strings.Split(params, ",") {
+		typ, err := abi.NewType(strings.TrimSpace(param), "", nil)
+		if err != nil {
+			return "", nil, fmt.Errorf("invalid call signature %s: %w", signature, err)
+		}
+		types = append(types, typ)
+	}
+	return name, types, nil
+}
+
+// parseValue converts an argument to the Go value packed by abi for typ, arrays are
+// given as "[a,b]", numbers in decimal or 0x prefixed hex, bytes in hex.
+func parseValue(typ abi.Type, arg string) (interface{}, error) {
+	switch typ.T {
+	case abi.AddressTy:
+		if !common.IsHexAddress(arg) {
+			return nil, fmt.Errorf("%s is not an address", arg)
+		}
+		return common.HexToAddress(arg), nil
+	case abi.BoolTy:
+		b, err := strconv.ParseBool(arg)
+		if err != nil {
+			return nil, fmt.Errorf("%s is not a bool", arg)
+		}
+		return b, nil
+	case abi.StringTy:
+		return arg, nil
+	case abi.BytesTy:
+		b, err := hexutil.Decode(arg)
+		if err != nil {
+			return nil, fmt.Errorf("%s is not hex encoded bytes", arg)
+		}
+		return b, nil
+	case abi.FixedBytesTy:
+		b, err := hexutil.Decode(arg)
+		if err != nil || len(b) != typ.Size {
+			return nil, fmt.Errorf("%s is not a hex encoded %s", arg, typ)
+		}
+		value := reflect.New(typ.GetType()).Elem()
+		reflect.Copy(value, reflect.ValueOf(b))
+		return value.Interface(), nil
+	case abi.UintTy, abi.IntTy:
+		return parseInteger(typ, arg)
+	case abi.SliceTy, abi.ArrayTy:
+		list, ok := strings.CutPrefix(strings.TrimSpace(arg), "[")
+		if list, ok = strings.CutSuffix(list, "]"); !ok {
+			return nil, fmt.Errorf("%s is not a %s, expected [a,b,...]", arg, typ)
+		}
+		var elems []string
+		if strings.TrimSpace(list) != "" {
+			var err error
+			if elems, err = splitList(list, ','); err != nil {
+				return nil, err
+			}
+		}
+		var value reflect.Value
+		if typ.T == abi.ArrayTy {
+			if len(elems) != typ.Size {
+				return nil, fmt.Errorf("%s has %d elements, expected %d", arg, len(elems), typ.Size)
+			}
+			value = reflect.New(typ.GetType()).Elem()
+		} else {
+			value = reflect.MakeSlice(typ.GetType(), len(elems), len(elems))
+		}
+		for i, elem := range elems {
+			v, err := parseValue(*typ.Elem, unquote(strings.TrimSpace(elem)))
+			if err != nil {
+				return nil, err
+			}
+			value.Index(i).Set(reflect.ValueOf(v))
+		}
+		return value.Interface(), nil
+	}
+	return nil, fmt.Errorf("unsupported type %s", typ)
+}
+
+// parseInteger returns a *big.Int, or the sized Go integer abi expects for types up to 64 bits.
+func parseInteger(typ abi.Type, arg string) (interface{}, error) {
+	n, ok := new(big.Int).SetString(arg, 0)
+	if !ok {
+		return nil, fmt.Errorf("%s is not a %s", arg, typ)
+	}
+	if typ.T == abi.UintTy {
+		if n.Sign() < 0 || n.BitLen() > typ.Size {
+			return nil, fmt.Errorf("%s is out of range for %s", arg, typ)
+		}
+	} else {
+		max := new(big.Int).Lsh(big.NewInt(1), uint(typ.Size-1))
+		min := new(big.Int).Neg(max)
+		if n.Cmp(min) < 0 || n.Cmp(max) >= 0 {
+			return nil, fmt.Errorf("%s is out of range for %s", arg, typ)
+		}
+	}
+	goType := typ.GetType()
+	if goType == reflect.TypeOf(n) {
+		return n, nil
+	}
+	if typ.T == abi.UintTy {
+		return reflect.ValueOf(n.Uint64()).Convert(goType).Interface(), nil
+	}
+	return reflect.ValueOf(n.Int64()).Convert(goType).Interface(), nil
+}
+
+// splitList splits s on sep, except inside double quotes or brackets.
+func splitList(s string, sep rune) ([]string, error) {
+	var parts []string
+	depth, quoted, start := 0, false, 0
+	for i, c := range s {
+		switch {
+		case c == '"':
+			quoted = !quoted
+		case quoted:
+		case c == '[':
+			depth++
+		case c == ']':
+			depth--
+		case c == sep && depth == 0:
+			parts = append(parts, s[start:i])
+			start = i + 1
+		}
+	}
+	if quoted || depth != 0 {
+		return nil, fmt.Errorf("unbalanced quotes or brackets in %s", s)
+	}
+	return append(parts, s[start:]), nil
+}
+
+// unquote removes the double quotes around an argument.
+func unquote(arg string) string {
+	if len(arg) >= 2 && strings.HasPrefix(arg, `"`) && strings.HasSuffix(arg, `"`) {
+		return arg[1 : len(arg)-1]
+	}
+	return arg
+}
+
+var call3Args = func() abi.Arguments {
+	call3Type, err := abi.NewType("tuple[]", "", []abi.ArgumentMarshaling{
+		{Name: "target", Type: "address"},
+		{Name: "allowFailure", Type: "bool"},
+		{Name: "callData", Type: "bytes"},
+	})
+	if err != nil {
+		panic(err)
+	}
+	return abi.Arguments{{Type: call3Type}}
+}()
+
+type call3 struct {
+	Target       common.Address
+	AllowFailure bool
+	CallData     []byte
+}
+
+// Pack ABI encodes encoded calls as an IMulticall3.Call3[].
+func Pack(calls []Call) (string, error) {
+	values := make([]call3, 0, len(calls))
+	for _, call := range calls {
+		calldata, err := hexutil.Decode(call.Calldata)
+		if err != nil {
+			return "", fmt.Errorf("invalid calldata for %s: %w", call.Signature, err)
+		}
+		values = append(values, call3{
+			Target:       common.HexToAddress(call.Target),
+			AllowFailure: call.AllowFailure,
+			CallData:     calldata,
+		})
+	}
+	packed, err := call3Args.Pack(values)
+	if err != nil {
+		return "", err
+	}
+	return hexutil.Encode(packed), nil
+}
+
+var aggregate3Selector = crypto.Keccak256([]byte("aggregate3((address,bool,bytes)[])"))[:4]
+
+// DecodeAggregate3 decodes the calldata of an aggregate3 call, i.e. the calls
+// executed by Multicall3, with their target and calldata.
+func DecodeAggregate3(data []byte) ([]Call, error) {
+	if len(data) < 4 || string(data[:4]) != string(aggregate3Selector) {
+		return nil, fmt.Errorf("data is not an aggregate3 call")
+	}
+	values, err := call3Args.Unpack(data[4:])
+	if err != nil {
+		return nil, fmt.Errorf("invalid aggregate3 calldata: %w", err)
+	}
+	var decoded []call3
+	if err := call3Args.Copy(&decoded, values); err != nil {
+		return nil, fmt.Errorf("invalid aggregate3 calldata: %w", err)
+	}
+	calls := make([]Call, 0, len(decoded))
+	for _, c := range decoded {
+		calls = append(calls, Call{
+			Target:       c.Target.Hex(),
+			AllowFailure: c.AllowFailure,
+			Calldata:     hexutil.Encode(c.CallData),
+		})
+	}
+	return calls, nil
+}
@@ -0,0 +1,150 @@
+package multicall
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common/hexutil"
+)
+
+const target = "0x2222222222222222222222222222222222222222"
+
+func TestParseSpec(t *testing.T) {
+	tests := []struct {
+		name    string
+		spec    string
+		want    Call
+		wantErr string
+	}{
+		{
+			name: "no arguments",
+			spec: target + ":unpause()",
+			want: Call{Target: target, Signature: "unpause()"},
+		},
+		{
+			name: "arguments",
+			spec: target + ":transfer(address,uint256):" + target + ":100",
+			want: Call{Target: target, Signature: "transfer(address,uint256)", Args: []string{target, "100"}},
+		},
+		{
+			name: "quoted argument",
+			spec: target + `:pause(string):"https://example.com"`,
+			want: Call{Target: target, Signature: "pause(string)", Args: []string{"https://example.com"}},
+		},
+		{
+			name:    "missing signature",
+			spec:    target,
+			wantErr: "expected target:signature",
+		},
+		{
+			name:    "invalid target",
+			spec:    "0x2222:unpause()",
+			wantErr: "invalid call target",
+		},
+		{
+			name:    "unquoted colon",
+			spec:    target + ":pause(string):https://example.com",
+			wantErr: "takes 1 arguments, got 2",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseSpec(tt.spec)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("expected error containing %q, got %v", tt.wantErr, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Fatalf("expected %+v, got %+v", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestEncode(t *testing.T) {
+	tests := []struct {
+		name    string
+		call    Call
+		want    string
+		wantErr bool
+	}{
+		{
+			name: "no arguments",
+			call: Call{Target: target, Signature: "unpause()"},
+			want: "0x3f4ba83a",
+		},
+		{
+			name: "string",
+			call: Call{Target: target, Signature: "pause(string)", Args: []string{"presigner"}},
+			want: "0x6da66355" +
+				"0000000000000000000000000000000000000000000000000000000000000020" +
+				"0000000000000000000000000000000000000000000000000000000000000009" +
+				"7072657369676e65720000000000000000000000000000000000000000000000",
+		},
+		{
+			name: "address and uint",
+			call: Call{Target: target, Signature: "transfer(address,uint256)", Args: []string{target, "0x10"}},
+			want: "0xa9059cbb" +
+				"0000000000000000000000002222222222222222222222222222222222222222" +
+				"0000000000000000000000000000000000000000000000000000000000000010",
+		},
+		{
+			name:    "invalid uint",
+			call:    Call{Target: target, Signature: "transfer(address,uint256)", Args: []string{target, "-1"}},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			calls := []Call{tt.call}
+			err := Encode(calls)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if calls[0].Calldata != tt.want {
+				t.Fatalf("expected %s, got %s", tt.want, calls[0].Calldata)
+			}
+		})
+	}
+}
+
+func TestPackDecodeAggregate3(t *testing.T) {
+	calls := []Call{
+		{Target: target, Calldata: "0x3f4ba83a"},
+		{Target: "0x3333333333333333333333333333333333333333", AllowFailure: true, Calldata: "0x"},
+	}
+	packed, err := Pack(calls)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data := append(append([]byte{}, aggregate3Selector...), hexutil.MustDecode(packed)...)
+
+	decoded, err := DecodeAggregate3(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(decoded) != len(calls) {
+		t.Fatalf("expected %d calls, got %d", len(calls), len(decoded))
+	}
+	for i, call := range decoded {
+		if !strings.EqualFold(call.Target, calls[i].Target) || call.AllowFailure != calls[i].AllowFailure || call.Calldata != calls[i].Calldata {
+			t.Fatalf("call %d: expected %+v, got %+v", i, calls[i], call)
+		}
+	}
+
+	if _, err := Pack([]Call{{Target: target, Calldata: "3f4ba83a"}}); err == nil {
+		t.Fatal("expected an error for calldata without 0x prefix")
+	}
+	if _, err := DecodeAggregate3(hexutil.MustDecode(packed)); err == nil {
+		t.Fatal("expected an error for data without the aggregate3 selector")
+	}
+	if _, err := DecodeAggregate3(data[:len(data)-40]); err == nil {
+		t.Fatal("expected an error for truncated data")
+	}
+}
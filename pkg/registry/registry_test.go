@@ -0,0 +1,119 @@
+package registry
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestResolveParams(t *testing.T) {
+	script := &Script{
+		Name: "Test",
+		Params: []Param{
+			{Name: "PAUSE_IDENTIFIER", Type: "string", Default: "presigner"},
+			{Name: "GUARDIAN", Type: "address"},
+			{Name: "AMOUNT", Type: "uint8", Default: "1"},
+			{Name: "HASHES", Type: "bytes32[]", Default: "0x0000000000000000000000000000000000000000000000000000000000000001"},
+		},
+	}
+	guardian := "0x2222222222222222222222222222222222222222"
+	hash := "0x000000000000000000000000000000000000000000000000000000000000000a"
+
+	tests := []struct {
+		name    string
+		given   map[string]string
+		want    map[string]string
+		wantErr string
+	}{
+		{
+			name:  "defaults",
+			given: map[string]string{"GUARDIAN": guardian},
+			want: map[string]string{
+				"PAUSE_IDENTIFIER": "presigner",
+				"GUARDIAN":         guardian,
+				"AMOUNT":           "1",
+				"HASHES":           "0x0000000000000000000000000000000000000000000000000000000000000001",
+			},
+		},
+		{
+			name: "given",
+			given: map[string]string{
+				"PAUSE_IDENTIFIER": "other",
+				"GUARDIAN":         guardian,
+				"AMOUNT":           "0xff",
+				"HASHES":           hash + ", " + hash,
+			},
+			want: map[string]string{
+				"PAUSE_IDENTIFIER": "other",
+				"GUARDIAN":         guardian,
+				"AMOUNT":           "0xff",
+				"HASHES":           hash + ", " + hash,
+			},
+		},
+		{
+			name:    "missing",
+			given:   map[string]string{},
+			wantErr: "missing parameter GUARDIAN (address)",
+		},
+		{
+			name:    "unknown",
+			given:   map[string]string{"GUARDIAN": guardian, "OTHER": "1"},
+			wantErr: "unknown parameter OTHER",
+		},
+		{
+			name:    "invalid address",
+			given:   map[string]string{"GUARDIAN": "0x2222"},
+			wantErr: "invalid parameter GUARDIAN",
+		},
+		{
+			name:    "uint overflow",
+			given:   map[string]string{"GUARDIAN": guardian, "AMOUNT": "256"},
+			wantErr: "invalid parameter AMOUNT",
+		},
+		{
+			name:    "invalid array element",
+			given:   map[string]string{"GUARDIAN": guardian, "HASHES": hash + ",0x01"},
+			wantErr: "invalid parameter HASHES",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := script.ResolveParams(tt.given)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("expected error containing %q, got %v", tt.wantErr, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Fatalf("expected %v, got %v", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestValidateValue(t *testing.T) {
+	tests := []struct {
+		typ     string
+		value   string
+		wantErr bool
+	}{
+		{typ: "bytes32", value: "0x" + strings.Repeat("01", 32)},
+		{typ: "bytes32", value: "0x01", wantErr: true},
+		{typ: "bytes4", value: "0x01020304"},
+		{typ: "bytes4", value: "0x" + strings.Repeat("01", 32), wantErr: true},
+		{typ: "bytes4", value: "0x010203", wantErr: true},
+		{typ: "bytes4[]", value: "0x01020304,0x05060708"},
+		{typ: "bytes33", value: "0x" + strings.Repeat("01", 33), wantErr: true},
+		{typ: "bytes", value: "0x010203"},
+	}
+	for _, tt := range tests {
+		err := validateValue(tt.typ, tt.value)
+		if (err != nil) != tt.wantErr {
+			t.Fatalf("unexpected error for %s %s: %v", tt.typ, tt.value, err)
+		}
+	}
+}
@@ -0,0 +1,173 @@
+package safe
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/ethereum-optimism/presigner/pkg/cast"
+)
+
+// ErrorCodes are the revert reasons of the safe contracts, see
+// https://github.com/safe-global/safe-contracts/blob/main/docs/error_codes.md
+var ErrorCodes = map[string]string{
+	"GS000": "Could not finish initialization",
+	"GS001": "Threshold needs to be defined",
+	"GS002": "A call to set up modules couldn't be executed because the destination account was not a contract",
+
+	"GS010": "Not enough gas to execute Safe transaction",
+	"GS011": "Could not pay gas costs with ether",
+	"GS012": "Could not pay gas costs with token",
+	"GS013": "Safe transaction failed when gasPrice and safeTxGas were 0",
+
+	"GS020": "Signatures data too short",
+	"GS021": "Invalid contract signature location: inside static part",
+	"GS022": "Invalid contract signature location: length not present",
+	"GS023": "Invalid contract signature location: data not complete",
+	"GS024": "Invalid contract signature provided",
+	"GS025": "Hash has not been approved",
+	"GS026": "Invalid owner provided",
+
+	"GS030": "Only owners can approve a hash",
+	"GS031": "Method can only be called from this contract",
+
+	"GS100": "Modules have already been initialized",
+	"GS101": "Invalid module address provided",
+	"GS102": "Module has already been added",
+	"GS103": "Invalid prevModule, module pair provided",
+	"GS104": "Method can only be called from an enabled module",
+	"GS105": "Invalid starting point for fetching paginated modules",
+	"GS106": "Invalid page size for fetching paginated modules",
+
+	"GS200": "Owners have already been set up",
+	"GS201": "Threshold cannot exceed owner count",
+	"GS202": "Threshold needs to be greater than 0",
+	"GS203": "Invalid owner address provided",
+	"GS204": "Address is already an owner",
+	"GS205": "Invalid prevOwner, owner pair provided",
+
+	"GS300": "Guard does not implement IERC165",
+
+	"GS400": "Fallback handler cannot be set to self",
+}
+
+// errorSelector is the selector of Error(string).
+const errorSelector = "08c379a0"
+
+var (
+	codeExp   = regexp.MustCompile(`\bGS\d{3}\b`)
+	revertExp = regexp.MustCompile(`0x` + errorSelector + `[0-9a-fA-F]*`)
+
+	// forge prints the reason of a script that reverted without the revert data
+	scriptFailedExp = regexp.MustCompile(`script failed: (?:revert: )?(.+)`)
+)
+
+type Failure struct {
+	Code        string `json:"code,omitempty"`
+	Description string `json:"description,omitempty"`
+	Explanation string `json:"explanation,omitempty"`
+	RevertData  string `json:"revert_data,omitempty"`
+	Reason      string `json:"reason,omitempty"`
+}
+
+// DecodeFailure extracts the revert data and safe error code from the output of a failed command.
+func DecodeFailure(output []byte) *Failure {
+	failure := &Failure{}
+
+	if revertData := revertExp.FindString(string(output)); revertData != "" {
+		failure.RevertData = revertData
+		if reason, err := decodeErrorString(revertData); err == nil {
+			failure.Reason = reason
+		}
+	}
+	if failure.Reason == "" {
+		if match := scriptFailedExp.FindStringSubmatch(string(output)); match != nil {
+			failure.Reason = strings.TrimSpace(match[1])
+		}
+	}
+
+	code := codeExp.FindString(failure.Reason)
+	if code == "" {
+		code = codeExp.FindString(string(output))
+	}
+	if description, ok := ErrorCodes[code]; ok {
+		failure.Code = code
+		failure.Description = description
+	}
+	return failure
+}
+
+// Reverted returns true if the command failed because the transaction reverted,
+// rather than e.g. a compilation or RPC error.
+func (f *Failure) Reverted() bool {
+	return f.Code != "" || f.RevertData != "" || f.Reason != ""
+}
+
+// ExplainContext is the on-chain and local state used to explain a failure.
+type ExplainContext struct {
+	// signers of the signatures passed to the safe
+	Signers []string
+	// signatures left out before they were passed to the safe
+	Dropped   []DroppedSignature
+	Owners    []string
+	Threshold int
+}
+
+// DroppedSignature is a signature left out of the quorum, with the reason.
+type DroppedSignature struct {
+	Signer string `json:"signer"`
+	Reason string `json:"reason"`
+}
+
+// Explain returns an actionable explanation for a safe error code.
+func Explain(code string, ctx ExplainContext) string {
+	switch code {
+	case "GS020":
+		explanation := "not enough signatures, collect more signatures and merge them"
+		if ctx.Threshold > 0 {
+			explanation = fmt.Sprintf("only %d of %d signatures, collect more signatures and merge them", len(ctx.Signers), ctx.Threshold)
+		}
+		for _, d := range ctx.Dropped {
+			explanation += fmt.Sprintf("; signature of %s was left out: %s", d.Signer, d.Reason)
+		}
+		return explanation
+	case "GS026":
+		var notOwners []string
+		for _, signer := range ctx.Signers {
+			if !containsAddress(ctx.Owners, signer) {
+				notOwners = append(notOwners, fmt.Sprintf("signer %s is not an owner", signer))
+			}
+		}
+		for _, d := range ctx.Dropped {
+			notOwners = append(notOwners, fmt.Sprintf("signature of %s was left out: %s", d.Signer, d.Reason))
+		}
+		if len(notOwners) > 0 {
+			return strings.Join(notOwners, "; ")
+		}
+		return "all signers are owners, signatures are either not sorted by signer or were produced for a different safe, nonce or transaction data"
+	case "GS025":
+		return "an owner provided a pre-validated signature but has not called approveHash for this transaction"
+	case "GS021", "GS022", "GS023", "GS024":
+		return "a contract signature is malformed or was rejected by the owner contract"
+	case "GS010":
+		return "increase the gas limit of the transaction"
+	case "GS013":
+		return "the inner call reverted, check that the target and calldata are correct and the safe is allowed to call it"
+	case "GS030":
+		return "approveHash was sent from an account that is not an owner of the safe"
+	}
+	return ""
+}
+
+func containsAddress(addrs []string, addr string) bool {
+	for _, a := range addrs {
+		if strings.EqualFold(a, addr) {
+			return true
+		}
+	}
+	return false
+}
+
+func decodeErrorString(revertData string) (string, error) {
+	return cast.DecodeString(strings.TrimPrefix(revertData, "0x"+errorSelector))
+}
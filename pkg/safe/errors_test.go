@@ -0,0 +1,103 @@
+package safe
+
+import (
+	"encoding/hex"
+	"fmt"
+	"testing"
+)
+
+// errorString returns the revert data of Error(reason).
+func errorString(reason string) string {
+	padded := make([]byte, (len(reason)+31)/32*32)
+	copy(padded, reason)
+	return fmt.Sprintf("0x%s%064x%064x%s", errorSelector, 32, len(reason), hex.EncodeToString(padded))
+}
+
+func TestDecodeFailure(t *testing.T) {
+	tests := []struct {
+		name     string
+		output   string
+		want     Failure
+		reverted bool
+	}{
+		{
+			name:     "script failed with code",
+			output:   "Error: \nscript failed: GS026\n",
+			want:     Failure{Code: "GS026", Description: ErrorCodes["GS026"], Reason: "GS026"},
+			reverted: true,
+		},
+		{
+			name:     "revert data",
+			output:   "Traces:\n  [1234] Safe::execTransaction(...)\n    └─ ← [Revert] " + errorString("GS013") + "\n",
+			want:     Failure{Code: "GS013", Description: ErrorCodes["GS013"], RevertData: errorString("GS013"), Reason: "GS013"},
+			reverted: true,
+		},
+		{
+			name:     "revert reason",
+			output:   "Error: \nscript failed: revert: Pausable: paused\n",
+			want:     Failure{Reason: "Pausable: paused"},
+			reverted: true,
+		},
+		{
+			name:     "unknown code",
+			output:   "Error: \nscript failed: GS999\n",
+			want:     Failure{Reason: "GS999"},
+			reverted: true,
+		},
+		{
+			name:   "compiler error",
+			output: "Error: \nCompiler run failed:\nError (7576): Undeclared identifier.\n",
+			want:   Failure{},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := DecodeFailure([]byte(tt.output))
+			if *got != tt.want {
+				t.Fatalf("expected %+v, got %+v", tt.want, *got)
+			}
+			if got.Reverted() != tt.reverted {
+				t.Fatalf("expected reverted %v, got %v", tt.reverted, got.Reverted())
+			}
+		})
+	}
+}
+
+func TestExplain(t *testing.T) {
+	owner := "0x2c7536e3605d9c16a7a3d7b1898e529396a65c23"
+	outsider := "0x3333333333333333333333333333333333333333"
+	dropped := []DroppedSignature{{Signer: outsider, Reason: "not an owner of safe 0x1111111111111111111111111111111111111111"}}
+	tests := []struct {
+		name string
+		code string
+		ctx  ExplainContext
+		want string
+	}{
+		{
+			name: "signatures below threshold",
+			code: "GS020",
+			ctx:  ExplainContext{Signers: []string{owner}, Dropped: dropped, Owners: []string{owner}, Threshold: 2},
+			want: "only 1 of 2 signatures, collect more signatures and merge them; signature of " + outsider +
+				" was left out: " + dropped[0].Reason,
+		},
+		{
+			name: "dropped signature",
+			code: "GS026",
+			ctx:  ExplainContext{Signers: []string{owner}, Dropped: dropped, Owners: []string{owner}, Threshold: 1},
+			want: "signature of " + outsider + " was left out: " + dropped[0].Reason,
+		},
+		{
+			name: "signer not an owner",
+			code: "GS026",
+			ctx:  ExplainContext{Signers: []string{outsider}, Owners: []string{owner}, Threshold: 1},
+			want: "signer " + outsider + " is not an owner",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Explain(tt.code, tt.ctx); got != tt.want {
+				t.Fatalf("expected %q, got %q", tt.want, got)
+			}
+		})
+	}
+}
@@ -0,0 +1,63 @@
+package safe
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/ethereum-optimism/presigner/pkg/cast"
+	"github.com/ethereum-optimism/presigner/pkg/shell"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+// LegacyEIP1271MagicValue is returned by the legacy isValidSignature(bytes,bytes) when the
+// signature is valid, it is the only interface checked by checkNSignatures of safe v1.3 and v1.4.
+const LegacyEIP1271MagicValue = "0x20c13b0b"
+
+// RecoverSigner returns the address that signed hash, with v = 27 or 28 as in safe signatures.
+func RecoverSigner(hash common.Hash, sig []byte) (common.Address, error) {
+	if len(sig) != crypto.SignatureLength {
+		return common.Address{}, fmt.Errorf("invalid signature length %d", len(sig))
+	}
+	if sig[64] != 27 && sig[64] != 28 {
+		return common.Address{}, fmt.Errorf("invalid signature v %d", sig[64])
+	}
+	rsv := make([]byte, crypto.SignatureLength)
+	copy(rsv, sig)
+	rsv[64] -= 27
+	pub, err := crypto.SigToPub(hash.Bytes(), rsv)
+	if err != nil {
+		return common.Address{}, err
+	}
+	return crypto.PubkeyToAddress(*pub), nil
+}
+
+// RecoverEthSignSigner returns the address that signed hash with eth_sign, i.e. with the
+// "\x19Ethereum Signed Message:\n32" prefix, with v = 31 or 32 as in safe signatures.
+func RecoverEthSignSigner(hash common.Hash, sig []byte) (common.Address, error) {
+	if len(sig) != crypto.SignatureLength {
+		return common.Address{}, fmt.Errorf("invalid signature length %d", len(sig))
+	}
+	if sig[64] != 31 && sig[64] != 32 {
+		return common.Address{}, fmt.Errorf("invalid eth_sign signature v %d", sig[64])
+	}
+	rsv := make([]byte, crypto.SignatureLength)
+	copy(rsv, sig)
+	rsv[64] -= 4
+	return RecoverSigner(crypto.Keccak256Hash([]byte("\x19Ethereum Signed Message:\n32"), hash.Bytes()), rsv)
+}
+
+// VerifyContractSignature calls the legacy isValidSignature on the owner contract with the EIP-712
+// data, as checkNSignatures does, so a signature valid only for the bytes32 variant is refused.
+func VerifyContractSignature(r shell.Runner, rpcUrl string, owner common.Address, data []byte, signature []byte) error {
+	out, err := cast.Call(r, rpcUrl, owner.Hex(), "isValidSignature(bytes,bytes)(bytes4)",
+		hexutil.Encode(data), hexutil.Encode(signature))
+	if err != nil {
+		return err
+	}
+	if strings.EqualFold(out, LegacyEIP1271MagicValue) {
+		return nil
+	}
+	return fmt.Errorf("isValidSignature of %s did not return the magic value", owner)
+}
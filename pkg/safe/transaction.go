@@ -0,0 +1,81 @@
+package safe
+
+import (
+	"fmt"
+	"math/big"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+)
+
+// Multicall3Address is the address MultisigBuilder delegatecalls to execute its IMulticall3.Call3[].
+const Multicall3Address = "0xcA11bde05977b3631167028862bE2a173976CA11"
+
+// Safe operations, as in Enum.Operation.
+const (
+	OperationCall         = "call"
+	OperationDelegateCall = "delegatecall"
+)
+
+// ExecTransaction holds the arguments of a decoded execTransaction call.
+type ExecTransaction struct {
+	To             common.Address
+	Value          *big.Int
+	Data           []byte
+	Operation      uint8
+	SafeTxGas      *big.Int
+	BaseGas        *big.Int
+	GasPrice       *big.Int
+	GasToken       common.Address
+	RefundReceiver common.Address
+	Signatures     []byte
+}
+
+// OperationName returns call or delegatecall.
+func (t *ExecTransaction) OperationName() string {
+	if t.Operation == 1 {
+		return OperationDelegateCall
+	}
+	return OperationCall
+}
+
+var execTransactionMethod = func() abi.Method {
+	parsed, err := abi.JSON(strings.NewReader(`[{"type":"function","name":"execTransaction","inputs":[
+		{"name":"to","type":"address"},
+		{"name":"value","type":"uint256"},
+		{"name":"data","type":"bytes"},
+		{"name":"operation","type":"uint8"},
+		{"name":"safeTxGas","type":"uint256"},
+		{"name":"baseGas","type":"uint256"},
+		{"name":"gasPrice","type":"uint256"},
+		{"name":"gasToken","type":"address"},
+		{"name":"refundReceiver","type":"address"},
+		{"name":"signatures","type":"bytes"}],
+		"outputs":[{"name":"success","type":"bool"}],"stateMutability":"payable"}]`))
+	if err != nil {
+		panic(err)
+	}
+	return parsed.Methods["execTransaction"]
+}()
+
+// DecodeExecTransaction decodes the calldata of an execTransaction call.
+func DecodeExecTransaction(calldata string) (*ExecTransaction, error) {
+	data, err := hexutil.Decode(calldata)
+	if err != nil {
+		return nil, fmt.Errorf("invalid calldata: %w", err)
+	}
+	if len(data) < 4 || string(data[:4]) != string(execTransactionMethod.ID) {
+		return nil, fmt.Errorf("calldata is not an execTransaction call")
+	}
+	var tx ExecTransaction
+	values, err := execTransactionMethod.Inputs.Unpack(data[4:])
+	if err != nil {
+		return nil, fmt.Errorf("invalid execTransaction calldata: %w", err)
+	}
+	if err := execTransactionMethod.Inputs.Copy(&tx, values); err != nil {
+		return nil, fmt.Errorf("invalid execTransaction calldata: %w", err)
+	}
+	return &tx, nil
+}
@@ -0,0 +1,281 @@
+package scaffold
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path"
+	"regexp"
+	"strings"
+	"text/template"
+	"unicode"
+
+	"github.com/ethereum/go-ethereum/accounts/abi"
+)
+
+var nameExp = regexp.MustCompile(`^[A-Z][A-Za-z0-9_]*$`)
+
+// reserved are the functions the template itself defines.
+var reserved = map[string]bool{
+	"_postCheck":  true,
+	"_buildCalls": true,
+	"_ownerSafe":  true,
+	"_targetAddr": true,
+}
+
+// Param is a function argument read by the generated script from the environment.
+type Param struct {
+	Name    string
+	Func    string
+	Env     string
+	Type    string
+	AbiType string
+	Getter  string
+}
+
+type script struct {
+	Name      string
+	Interface string
+	Function  string
+	Params    []Param
+}
+
+func (s script) Declaration() string {
+	decls := make([]string, 0, len(s.Params))
+	for _, p := range s.Params {
+		decls = append(decls, p.Type+" "+p.Name)
+	}
+	return strings.Join(decls, ", ")
+}
+
+func (s script) Args() string {
+	args := make([]string, 0, len(s.Params))
+	for _, p := range s.Params {
+		args = append(args, p.Func+"()")
+	}
+	return strings.Join(args, ", ")
+}
+
+var scriptTemplate = template.Must(template.New("script").Parse(`// SPDX-License-Identifier: UNLICENSED
+pragma solidity ^0.8.15;
+
+import "forge-std/console.sol";
+import "@base-contracts/script/universal/MultisigBuilder.sol";
+import {IGnosisSafe} from "@eth-optimism-bedrock/scripts/interfaces/IGnosisSafe.sol";
+
+interface {{.Interface}} {
+    function {{.Function}}({{.Declaration}}) external;
+}
+
+contract {{.Name}} is MultisigBuilder {
+    function _postCheck() internal view override {
+        IGnosisSafe safe = IGnosisSafe(_ownerSafe());
+        console.log("Nonce post check", safe.nonce());
+    }
+
+    function _buildCalls() internal view override returns (IMulticall3.Call3[] memory) {
+        IMulticall3.Call3[] memory calls = new IMulticall3.Call3[](1);
+
+        calls[0] = IMulticall3.Call3({
+            target: _targetAddr(),
+            allowFailure: false,
+            callData: abi.encodeCall({{.Interface}}.{{.Function}}, ({{.Args}}))
+        });
+
+        return calls;
+    }
+
+    function _ownerSafe() internal view override returns (address) {
+        return vm.envAddress("SAFE_ADDR");
+    }
+
+    function _targetAddr() internal view returns (address) {
+        return vm.envAddress("TARGET_ADDR");
+    }
+{{- range .Params}}
+
+    function {{.Func}}() internal view returns ({{.Type}}) {
+        return {{.Getter}};
+    }
+{{- end}}
+}
+`))
+
+// Generate renders a MultisigBuilder script calling function of the contract described by abiFile.
+// The abi file can be either a plain ABI or a forge artifact.
+func Generate(name, abiFile, function string) (string, []Param, error) {
+	if !nameExp.MatchString(name) {
+		return "", nil, fmt.Errorf("invalid script name: %s", name)
+	}
+
+	contractAbi, contractName, err := readAbi(abiFile)
+	if err != nil {
+		return "", nil, err
+	}
+	if contractName == "" {
+		contractName = strings.TrimSuffix(path.Base(abiFile), path.Ext(abiFile))
+	}
+	if !nameExp.MatchString("I" + contractName) {
+		return "", nil, fmt.Errorf("invalid contract name %q to name the interface after, rename %s", contractName, abiFile)
+	}
+
+	var method *abi.Method
+	for _, m := range contractAbi.Methods {
+		if m.RawName == function || m.Sig == function {
+			if method != nil {
+				return "", nil, fmt.Errorf("function %s is overloaded, use the full signature, e.g. %s", function, m.Sig)
+			}
+			m := m
+			method = &m
+		}
+	}
+	if method == nil {
+		return "", nil, fmt.Errorf("function %s not found in %s", function, abiFile)
+	}
+
+	s := script{
+		Name:      name,
+		Interface: "I" + contractName,
+		Function:  method.RawName,
+	}
+	argByEnv := make(map[string]string)
+	for i, input := range method.Inputs {
+		p, err := newParam(i, input)
+		if err != nil {
+			return "", nil, err
+		}
+		if other, ok := argByEnv[p.Env]; ok {
+			return "", nil, fmt.Errorf("arguments %s and %s would both be read from %s", other, input.Name, p.Env)
+		}
+		argByEnv[p.Env] = input.Name
+		s.Params = append(s.Params, p)
+	}
+
+	var out bytes.Buffer
+	if err := scriptTemplate.Execute(&out, s); err != nil {
+		return "", nil, err
+	}
+	return out.String(), s.Params, nil
+}
+
+// readAbi returns the abi of the file, and the contract name if it is a forge artifact.
+func readAbi(abiFile string) (*abi.ABI, string, error) {
+	contents, err := os.ReadFile(abiFile)
+	if err != nil {
+		return nil, "", err
+	}
+	// forge artifacts wrap the abi in an object, with the contract name in the compiler metadata
+	var artifact struct {
+		Abi      json.RawMessage `json:"abi"`
+		Metadata json.RawMessage `json:"metadata"`
+	}
+	contractName := ""
+	if json.Unmarshal(contents, &artifact) == nil && len(artifact.Abi) > 0 {
+		contents = artifact.Abi
+		var metadata struct {
+			Settings struct {
+				CompilationTarget map[string]string `json:"compilationTarget"`
+			} `json:"settings"`
+		}
+		// best effort, older artifacts store the metadata as a string
+		if json.Unmarshal(artifact.Metadata, &metadata) == nil {
+			for _, name := range metadata.Settings.CompilationTarget {
+				contractName = name
+			}
+		}
+	}
+	contractAbi, err := abi.JSON(bytes.NewReader(contents))
+	if err != nil {
+		return nil, "", fmt.Errorf("invalid abi: %w", err)
+	}
+	return &contractAbi, contractName, nil
+}
+
+func newParam(i int, input abi.Argument) (Param, error) {
+	name := strings.Trim(input.Name, "_")
+	if name == "" {
+		name = fmt.Sprintf("arg%d", i)
+	}
+	p := Param{
+		Name: "_" + name,
+		Func: "_" + name,
+		Env:  envName(name),
+	}
+	if reserved[p.Func] {
+		p.Func = "_arg" + strings.ToUpper(name[:1]) + name[1:]
+	}
+
+	switch p.Env {
+	case "SAFE_ADDR", "SAFE_NONCE", "TARGET_ADDR", "TARGET_ADDRS":
+		return Param{}, fmt.Errorf("argument %s clashes with %s set by presigner", input.Name, p.Env)
+	}
+
+	solType, getter, err := envGetter(input.Type, p.Env)
+	if err != nil {
+		return Param{}, fmt.Errorf("argument %s: %w", input.Name, err)
+	}
+	p.Type = solType
+	p.AbiType = input.Type.String()
+	p.Getter = getter
+	return p, nil
+}
+
+// envName converts a camelCase argument name into an environment variable name.
+func envName(name string) string {
+	var out []rune
+	runes := []rune(name)
+	for i, r := range runes {
+		if i > 0 && unicode.IsUpper(r) && !unicode.IsUpper(runes[i-1]) {
+			out = append(out, '_')
+		}
+		out = append(out, unicode.ToUpper(r))
+	}
+	return string(out)
+}
+
+// envGetter returns the solidity type and the forge cheatcode reading a value of type t from env.
+func envGetter(t abi.Type, env string) (string, string, error) {
+	switch t.T {
+	case abi.AddressTy:
+		return "address", fmt.Sprintf("vm.envAddress(%q)", env), nil
+	case abi.BoolTy:
+		return "bool", fmt.Sprintf("vm.envBool(%q)", env), nil
+	case abi.UintTy:
+		if t.Size == 256 {
+			return "uint256", fmt.Sprintf("vm.envUint(%q)", env), nil
+		}
+		return t.String(), fmt.Sprintf("%s(vm.envUint(%q))", t.String(), env), nil
+	case abi.IntTy:
+		if t.Size == 256 {
+			return "int256", fmt.Sprintf("vm.envInt(%q)", env), nil
+		}
+		return t.String(), fmt.Sprintf("%s(vm.envInt(%q))", t.String(), env), nil
+	case abi.FixedBytesTy:
+		if t.Size == 32 {
+			return "bytes32", fmt.Sprintf("vm.envBytes32(%q)", env), nil
+		}
+		// envBytes32 would need 32 bytes, the value holds exactly the N bytes of the type
+		return t.String(), fmt.Sprintf("%s(vm.envBytes(%q))", t.String(), env), nil
+	case abi.StringTy:
+		return "string memory", fmt.Sprintf("vm.envString(%q)", env), nil
+	case abi.BytesTy:
+		return "bytes memory", fmt.Sprintf("vm.envBytes(%q)", env), nil
+	case abi.SliceTy:
+		elem := t.Elem.String()
+		switch elem {
+		case "address", "bool", "uint256", "int256", "bytes32", "string", "bytes":
+			cheatcode := map[string]string{
+				"address": "envAddress",
+				"bool":    "envBool",
+				"uint256": "envUint",
+				"int256":  "envInt",
+				"bytes32": "envBytes32",
+				"string":  "envString",
+				"bytes":   "envBytes",
+			}[elem]
+			return elem + "[] memory", fmt.Sprintf("vm.%s(%q, \",\")", cheatcode, env), nil
+		}
+	}
+	return "", "", fmt.Errorf("type %s cannot be read from the environment", t.String())
+}
@@ -0,0 +1,254 @@
+package scaffold
+
+import (
+	"os"
+	"path"
+	"strings"
+	"testing"
+)
+
+const pauseAbi = `[
+	{"type": "function", "name": "pause", "stateMutability": "nonpayable", "outputs": [],
+	 "inputs": [{"name": "_identifier", "type": "string"}]}
+]`
+
+func writeAbi(t *testing.T, name, contents string) string {
+	t.Helper()
+	file := path.Join(t.TempDir(), name)
+	if err := os.WriteFile(file, []byte(contents), 0600); err != nil {
+		t.Fatal(err)
+	}
+	return file
+}
+
+func function(name string, inputs ...string) string {
+	return `{"type": "function", "name": "` + name + `", "stateMutability": "nonpayable", "outputs": [], "inputs": [` +
+		strings.Join(inputs, ",") + `]}`
+}
+
+func input(name, typ string) string {
+	return `{"name": "` + name + `", "type": "` + typ + `"}`
+}
+
+func TestGenerate(t *testing.T) {
+	abiFile := writeAbi(t, "SuperchainConfig.json", pauseAbi)
+	out, params, err := Generate("PauseScript", abiFile, "pause")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := Param{
+		Name:    "_identifier",
+		Func:    "_identifier",
+		Env:     "IDENTIFIER",
+		Type:    "string memory",
+		AbiType: "string",
+		Getter:  `vm.envString("IDENTIFIER")`,
+	}
+	if len(params) != 1 || params[0] != want {
+		t.Fatalf("expected params %+v, got %+v", want, params)
+	}
+	for _, s := range []string{
+		"interface ISuperchainConfig {\n    function pause(string memory _identifier) external;\n}",
+		"contract PauseScript is MultisigBuilder {",
+		"callData: abi.encodeCall(ISuperchainConfig.pause, (_identifier()))",
+		"function _identifier() internal view returns (string memory) {\n        return vm.envString(\"IDENTIFIER\");\n    }",
+	} {
+		if !strings.Contains(out, s) {
+			t.Fatalf("expected the script to contain %q, got:\n%s", s, out)
+		}
+	}
+}
+
+func TestGenerateArtifact(t *testing.T) {
+	artifact := `{
+		"abi": ` + pauseAbi + `,
+		"bytecode": {"object": "0x"},
+		"metadata": {"settings": {"compilationTarget": {"src/L1/OptimismPortal.sol": "OptimismPortal"}}}
+	}`
+	out, _, err := Generate("PauseScript", writeAbi(t, "artifact.json", artifact), "pause")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(out, "abi.encodeCall(IOptimismPortal.pause, (_identifier()))") {
+		t.Fatalf("expected the interface named after the contract of the artifact, got:\n%s", out)
+	}
+
+	// older artifacts store the metadata as a string, the file name is used instead
+	artifact = `{"abi": ` + pauseAbi + `, "metadata": "{}"}`
+	out, _, err = Generate("PauseScript", writeAbi(t, "Portal.json", artifact), "pause")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(out, "interface IPortal {") {
+		t.Fatalf("expected the interface named after the file, got:\n%s", out)
+	}
+}
+
+func TestGenerateParams(t *testing.T) {
+	tests := []struct {
+		name     string
+		function string
+		inputs   []string
+		want     []Param
+		wantErr  string
+	}{
+		{
+			name:     "types",
+			function: "configure",
+			inputs: []string{
+				input("_guardian", "address"),
+				input("gasLimit", "uint64"),
+				input("", "int256"),
+				input("selector", "bytes4"),
+				input("hashes", "bytes32[]"),
+			},
+			want: []Param{
+				{Name: "_guardian", Func: "_guardian", Env: "GUARDIAN", Type: "address", AbiType: "address", Getter: `vm.envAddress("GUARDIAN")`},
+				{Name: "_gasLimit", Func: "_gasLimit", Env: "GAS_LIMIT", Type: "uint64", AbiType: "uint64", Getter: `uint64(vm.envUint("GAS_LIMIT"))`},
+				{Name: "_arg2", Func: "_arg2", Env: "ARG2", Type: "int256", AbiType: "int256", Getter: `vm.envInt("ARG2")`},
+				{Name: "_selector", Func: "_selector", Env: "SELECTOR", Type: "bytes4", AbiType: "bytes4", Getter: `bytes4(vm.envBytes("SELECTOR"))`},
+				{Name: "_hashes", Func: "_hashes", Env: "HASHES", Type: "bytes32[] memory", AbiType: "bytes32[]", Getter: `vm.envBytes32("HASHES", ",")`},
+			},
+		},
+		{
+			name:     "reserved function",
+			function: "check",
+			inputs:   []string{input("_postCheck", "bool"), input("ownerSafe", "address")},
+			want: []Param{
+				{Name: "_postCheck", Func: "_argPostCheck", Env: "POST_CHECK", Type: "bool", AbiType: "bool", Getter: `vm.envBool("POST_CHECK")`},
+				{Name: "_ownerSafe", Func: "_argOwnerSafe", Env: "OWNER_SAFE", Type: "address", AbiType: "address", Getter: `vm.envAddress("OWNER_SAFE")`},
+			},
+		},
+		{
+			name:     "presigner env var",
+			function: "transfer",
+			inputs:   []string{input("_safeAddr", "address")},
+			wantErr:  "argument _safeAddr clashes with SAFE_ADDR set by presigner",
+		},
+		{
+			name:     "target env var",
+			function: "transfer",
+			inputs:   []string{input("targetAddrs", "address[]")},
+			wantErr:  "clashes with TARGET_ADDRS",
+		},
+		{
+			name:     "same env var",
+			function: "set",
+			inputs:   []string{input("gasLimit", "uint256"), input("_gas_limit", "uint256")},
+			wantErr:  "arguments gasLimit and _gas_limit would both be read from GAS_LIMIT",
+		},
+		{
+			name:     "unsupported array",
+			function: "set",
+			inputs:   []string{input("values", "uint8[]")},
+			wantErr:  "argument values: type uint8[] cannot be read from the environment",
+		},
+		{
+			name:     "unsupported fixed array",
+			function: "set",
+			inputs:   []string{input("owners", "address[2]")},
+			wantErr:  "type address[2] cannot be read from the environment",
+		},
+		{
+			name:     "unsupported tuple",
+			function: "set",
+			inputs: []string{`{"name": "config", "type": "tuple", "internalType": "struct Config",
+				"components": [{"name": "gasLimit", "type": "uint64"}]}`},
+			wantErr: "type (uint64) cannot be read from the environment",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			abiFile := writeAbi(t, "Target.json", "["+function(tt.function, tt.inputs...)+"]")
+			out, params, err := Generate("TestScript", abiFile, tt.function)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("expected error containing %q, got %v", tt.wantErr, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(params) != len(tt.want) {
+				t.Fatalf("expected params %+v, got %+v", tt.want, params)
+			}
+			for i := range params {
+				if params[i] != tt.want[i] {
+					t.Fatalf("expected param %+v, got %+v", tt.want[i], params[i])
+				}
+				if !strings.Contains(out, "function "+params[i].Func+"() internal view") {
+					t.Fatalf("expected the script to define %s, got:\n%s", params[i].Func, out)
+				}
+			}
+		})
+	}
+}
+
+func TestGenerateOverloads(t *testing.T) {
+	abiFile := writeAbi(t, "Target.json", "["+
+		function("set", input("value", "uint256"))+","+
+		function("set", input("value", "address"))+"]")
+
+	if _, _, err := Generate("TestScript", abiFile, "set"); err == nil || !strings.Contains(err.Error(), "is overloaded, use the full signature") {
+		t.Fatalf("expected an overload error, got %v", err)
+	}
+	out, params, err := Generate("TestScript", abiFile, "set(address)")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(params) != 1 || params[0].Type != "address" {
+		t.Fatalf("expected the address overload, got %+v", params)
+	}
+	if !strings.Contains(out, "function set(address _value) external;") {
+		t.Fatalf("expected the address overload in the interface, got:\n%s", out)
+	}
+}
+
+func TestGenerateInvalid(t *testing.T) {
+	abiFile := writeAbi(t, "Target.json", pauseAbi)
+	tests := []struct {
+		name     string
+		script   string
+		abiFile  string
+		function string
+		wantErr  string
+	}{
+		{
+			name:     "script name",
+			script:   "pauseScript",
+			abiFile:  abiFile,
+			function: "pause",
+			wantErr:  "invalid script name: pauseScript",
+		},
+		{
+			name:     "missing function",
+			script:   "PauseScript",
+			abiFile:  abiFile,
+			function: "unpause",
+			wantErr:  "function unpause not found",
+		},
+		{
+			name:     "contract name",
+			script:   "PauseScript",
+			abiFile:  writeAbi(t, "target-v2.json", pauseAbi),
+			function: "pause",
+			wantErr:  "invalid contract name \"target-v2\"",
+		},
+		{
+			name:     "invalid abi",
+			script:   "PauseScript",
+			abiFile:  writeAbi(t, "Target.json", "{}"),
+			function: "pause",
+			wantErr:  "invalid abi",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, _, err := Generate(tt.script, tt.abiFile, tt.function)
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Fatalf("expected error containing %q, got %v", tt.wantErr, err)
+			}
+		})
+	}
+}
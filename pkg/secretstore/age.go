@@ -0,0 +1,130 @@
+package secretstore
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path"
+	"sort"
+	"strings"
+
+	"github.com/ethereum-optimism/presigner/pkg/shell"
+)
+
+const (
+	ageExt      = ".age"
+	metadataExt = ".meta.json"
+)
+
+// AgeDir stores items as files of a local directory encrypted with the age CLI,
+// the metadata is stored in clear next to them.
+// age runs on the terminal, it prompts for the passphrase of an encrypted identity.
+type AgeDir struct {
+	Runner     shell.Runner
+	Dir        string
+	Recipients []string
+
+	// identity file used to decrypt, age has no default identity so it is required to read items
+	Identity string
+}
+
+func (a *AgeDir) file(item string) string {
+	return path.Join(a.Dir, item+ageExt)
+}
+
+func (a *AgeDir) metadataFile(item string) string {
+	return path.Join(a.Dir, item+metadataExt)
+}
+
+func (a *AgeDir) List() ([]Item, error) {
+	entries, err := os.ReadDir(a.Dir)
+	if os.IsNotExist(err) {
+		return []Item{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	items := make([]Item, 0, len(entries))
+	for _, entry := range entries {
+		name, ok := strings.CutSuffix(entry.Name(), ageExt)
+		if !ok || entry.IsDir() {
+			continue
+		}
+		item := Item{Name: name}
+		if contents, err := os.ReadFile(a.metadataFile(name)); err == nil {
+			if err := json.Unmarshal(contents, &item.Metadata); err != nil {
+				return nil, fmt.Errorf("invalid metadata of %s: %w", name, err)
+			}
+		}
+		items = append(items, item)
+	}
+	sort.Slice(items, func(i, k int) bool {
+		return items[i].Name < items[k].Name
+	})
+	return items, nil
+}
+
+func (a *AgeDir) Get(item string) ([]byte, error) {
+	if !shell.ExistFile(a.file(item)) {
+		return nil, ErrNotFound
+	}
+	args := []string{"--decrypt"}
+	if a.Identity != "" {
+		args = append(args, "--identity", a.Identity)
+	}
+	args = append(args, a.file(item))
+	outBuffer, _, err := a.Runner.RunTerminal("age", []string{}, "", true, args...)
+	if err != nil {
+		return nil, fmt.Errorf("error decrypting %s: %w", a.file(item), err)
+	}
+	return outBuffer, nil
+}
+
+func (a *AgeDir) Put(item string, contents []byte, metadata map[string]string) error {
+	if err := os.MkdirAll(a.Dir, 0700); err != nil {
+		return err
+	}
+	// encrypted next to the item and renamed, so a failure does not truncate an existing item
+	tmpFile := a.file(item) + ".tmp"
+	args := []string{"--encrypt", "--output", tmpFile}
+	for _, recipient := range a.Recipients {
+		args = append(args, "--recipient", recipient)
+	}
+	if _, _, err := a.Runner.RunTerminal("age", []string{}, string(contents), true, args...); err != nil {
+		os.Remove(tmpFile)
+		return fmt.Errorf("error encrypting %s: %w", a.file(item), err)
+	}
+	if err := os.Rename(tmpFile, a.file(item)); err != nil {
+		return err
+	}
+	if len(metadata) == 0 {
+		if err := os.Remove(a.metadataFile(item)); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		return nil
+	}
+	jsonContents, err := json.Marshal(metadata)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(a.metadataFile(item), jsonContents, 0600)
+}
+
+// Replace is the same as Put, the file is renamed over the existing one once encrypted.
+func (a *AgeDir) Replace(item string, contents []byte, metadata map[string]string) error {
+	return a.Put(item, contents, metadata)
+}
+
+func (a *AgeDir) Delete(item string) error {
+	err := os.Remove(a.file(item))
+	if os.IsNotExist(err) {
+		return ErrNotFound
+	}
+	if err != nil {
+		return err
+	}
+	if err := os.Remove(a.metadataFile(item)); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
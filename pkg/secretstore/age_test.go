@@ -0,0 +1,124 @@
+package secretstore
+
+import (
+	"errors"
+	"os"
+	"path"
+	"slices"
+	"testing"
+
+	"github.com/ethereum-optimism/presigner/pkg/shell"
+)
+
+const ageRecipient = "age1ql3z7hjy54pw3hyww5ayyfg7zqgvc7w3j2elw8zmrj2kg5sfn9aqmcac8p"
+
+// ageRunner replays the age commands, writing their input to the --output file as age would.
+type ageRunner struct {
+	shell.Replayer
+}
+
+func (a *ageRunner) RunTerminal(name string, env []string, in string, silent bool, args ...string) ([]byte, []byte, error) {
+	outBuffer, errBuffer, err := a.Replayer.RunTerminal(name, env, in, silent, args...)
+	if i := slices.Index(args, "--output"); err == nil && i >= 0 {
+		err = os.WriteFile(args[i+1], []byte("encrypted "+in), 0600)
+	}
+	return outBuffer, errBuffer, err
+}
+
+func encryptCall(file, contents string, exitCode int) shell.Call {
+	return shell.Call{
+		Name:     "age",
+		Args:     []string{"--encrypt", "--output", file + ".tmp", "--recipient", ageRecipient},
+		Stdin:    contents,
+		ExitCode: exitCode,
+	}
+}
+
+func TestAgeDir(t *testing.T) {
+	dir := path.Join(t.TempDir(), "vault")
+	file := path.Join(dir, "tx.json.age")
+	r := &ageRunner{shell.Replayer{Calls: []shell.Call{
+		encryptCall(file, "{}", 0),
+		{Name: "age", Args: []string{"--decrypt", "--identity", "key.txt", file}, Stdout: "{}"},
+		encryptCall(file, "{\"nonce\":1}", 1),
+		encryptCall(file, "{\"nonce\":2}", 0),
+	}}}
+	a := &AgeDir{Runner: r, Dir: dir, Recipients: []string{ageRecipient}, Identity: "key.txt"}
+
+	items, err := a.List()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(items) != 0 {
+		t.Fatalf("expected no items before the directory is created, got %v", items)
+	}
+
+	if err := a.Put("tx.json", []byte("{}"), map[string]string{"safe": "0x1111"}); err != nil {
+		t.Fatal(err)
+	}
+	if got := readFile(t, file); got != "encrypted {}" {
+		t.Fatalf("expected the encrypted item, got %q", got)
+	}
+	items, err = a.List()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(items) != 1 || items[0].Name != "tx.json" || FormatMetadata(items[0].Metadata) != "safe=0x1111" {
+		t.Fatalf("expected tx.json with its metadata, got %v", items)
+	}
+
+	contents, err := a.Get("tx.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(contents) != "{}" {
+		t.Fatalf("expected the decrypted item, got %q", contents)
+	}
+	if _, err := a.Get("missing.json"); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("expected ErrNotFound, got %v", err)
+	}
+
+	// a failed encryption keeps the previous item
+	if err := a.Replace("tx.json", []byte("{\"nonce\":1}"), nil); err == nil {
+		t.Fatal("expected an error")
+	}
+	if got := readFile(t, file); got != "encrypted {}" {
+		t.Fatalf("expected the previous item, got %q", got)
+	}
+	if shell.ExistFile(file + ".tmp") {
+		t.Fatal("expected the temporary file to be removed")
+	}
+
+	// replaced without metadata, the previous metadata is removed
+	if err := a.Replace("tx.json", []byte("{\"nonce\":2}"), nil); err != nil {
+		t.Fatal(err)
+	}
+	if shell.ExistFile(path.Join(dir, "tx.json.meta.json")) {
+		t.Fatal("expected the metadata to be removed")
+	}
+
+	if err := a.Delete("tx.json"); err != nil {
+		t.Fatal(err)
+	}
+	if err := a.Delete("tx.json"); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("expected ErrNotFound, got %v", err)
+	}
+}
+
+func TestOpenAgeDir(t *testing.T) {
+	c := DefaultConfig()
+	c.Backend = BackendAge
+	if _, err := c.Open(&shell.Replayer{}, "."); err == nil {
+		t.Fatal("expected an error without recipients")
+	}
+
+	workdir := t.TempDir()
+	c.Age.Recipients = []string{ageRecipient}
+	store, err := c.Open(&shell.Replayer{}, workdir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if dir := store.(*AgeDir).Dir; dir != path.Join(workdir, "vault") {
+		t.Fatalf("expected the directory relative to the workdir, got %s", dir)
+	}
+}
@@ -0,0 +1,44 @@
+package secretstore
+
+import (
+	"github.com/ethereum-optimism/presigner/pkg/envelope"
+)
+
+// Encrypted encrypts the contents put in a store, and decrypts the ones it gets,
+// items stored in plaintext are returned unchanged.
+type Encrypted struct {
+	Store    SecretStore
+	Envelope *envelope.Envelope
+}
+
+func (e *Encrypted) List() ([]Item, error) {
+	return e.Store.List()
+}
+
+func (e *Encrypted) Get(item string) ([]byte, error) {
+	contents, err := e.Store.Get(item)
+	if err != nil {
+		return nil, err
+	}
+	return e.Envelope.Open(contents)
+}
+
+func (e *Encrypted) Put(item string, contents []byte, metadata map[string]string) error {
+	sealed, err := e.Envelope.Seal(contents)
+	if err != nil {
+		return err
+	}
+	return e.Store.Put(item, sealed, metadata)
+}
+
+func (e *Encrypted) Replace(item string, contents []byte, metadata map[string]string) error {
+	sealed, err := e.Envelope.Seal(contents)
+	if err != nil {
+		return err
+	}
+	return e.Store.Replace(item, sealed, metadata)
+}
+
+func (e *Encrypted) Delete(item string) error {
+	return e.Store.Delete(item)
+}
@@ -0,0 +1,140 @@
+package secretstore
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/ethereum-optimism/presigner/pkg/shell"
+)
+
+// OnePassword stores items in a 1Password vault with the op CLI,
+// the contents are base64 encoded in the text field of a Login item,
+// and the metadata is attached as key=value tags, one per element of a list.
+// op runs on the terminal, it prompts for the account password when signed out.
+type OnePassword struct {
+	Runner  shell.Runner
+	Account string
+	Vault   string
+}
+
+func (o *OnePassword) List() ([]Item, error) {
+	outBuffer, _, err := o.Runner.RunTerminal("op", []string{}, "", true,
+		"--format", "json",
+		"--account", o.Account,
+		"--vault", o.Vault,
+		"item",
+		"list")
+	if err != nil {
+		return nil, err
+	}
+	var j []struct {
+		Title string   `json:"title"`
+		Tags  []string `json:"tags"`
+	}
+	if err := json.Unmarshal(outBuffer, &j); err != nil {
+		return nil, fmt.Errorf("invalid item list from op: %w", err)
+	}
+	items := make([]Item, 0, len(j))
+	for _, jitem := range j {
+		items = append(items, Item{
+			Name:     strings.TrimSpace(jitem.Title),
+			Metadata: parseTags(jitem.Tags),
+		})
+	}
+	sort.Slice(items, func(i, k int) bool {
+		return items[i].Name < items[k].Name
+	})
+	return items, nil
+}
+
+func formatTags(metadata map[string]string) string {
+	var tags []string
+	for key, value := range metadata {
+		for _, v := range strings.Split(value, ",") {
+			if v != "" {
+				tags = append(tags, key+"="+v)
+			}
+		}
+	}
+	sort.Strings(tags)
+	return strings.Join(tags, ",")
+}
+
+func parseTags(tags []string) map[string]string {
+	metadata := make(map[string]string)
+	for _, tag := range tags {
+		key, value, ok := strings.Cut(tag, "=")
+		if !ok {
+			continue
+		}
+		if metadata[key] != "" {
+			value = metadata[key] + "," + value
+		}
+		metadata[key] = value
+	}
+	return metadata
+}
+
+func (o *OnePassword) Get(item string) ([]byte, error) {
+	outBuffer, _, err := o.Runner.RunTerminal("op", []string{}, "", true,
+		"--account", o.Account,
+		"read",
+		fmt.Sprintf("op://%s/%s/text", o.Vault, item))
+	// op exits with 1 for any error, the reason is only in stderr
+	if exitErr, ok := shell.IsExitError(err); ok && strings.Contains(string(exitErr.Stderr), "isn't an item") {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	decoded, err := base64.StdEncoding.DecodeString(strings.TrimSpace(string(outBuffer)))
+	if err != nil {
+		return nil, fmt.Errorf("error decoding base64: %w", err)
+	}
+	return decoded, nil
+}
+
+func (o *OnePassword) Put(item string, contents []byte, metadata map[string]string) error {
+	b64 := base64.StdEncoding.EncodeToString(contents)
+	args := []string{
+		"--account", o.Account,
+		"--vault", o.Vault,
+		"item",
+		"create",
+		"--title", item,
+		"--category", "Login",
+	}
+	if tags := formatTags(metadata); tags != "" {
+		args = append(args, "--tags", tags)
+	}
+	args = append(args, fmt.Sprintf("text=%s", b64))
+	_, _, err := o.Runner.RunTerminal("op", []string{}, "", true, args...)
+	return err
+}
+
+func (o *OnePassword) Replace(item string, contents []byte, metadata map[string]string) error {
+	b64 := base64.StdEncoding.EncodeToString(contents)
+	// the tags replace the previous ones, an empty list removes them
+	_, _, err := o.Runner.RunTerminal("op", []string{}, "", true,
+		"--account", o.Account,
+		"--vault", o.Vault,
+		"item",
+		"edit",
+		item,
+		"--tags", formatTags(metadata),
+		fmt.Sprintf("text=%s", b64))
+	return err
+}
+
+func (o *OnePassword) Delete(item string) error {
+	_, _, err := o.Runner.RunTerminal("op", []string{}, "", true,
+		"--account", o.Account,
+		"--vault", o.Vault,
+		"item",
+		"delete",
+		item)
+	return err
+}
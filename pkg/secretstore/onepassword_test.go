@@ -0,0 +1,100 @@
+package secretstore
+
+import (
+	"encoding/base64"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/ethereum-optimism/presigner/pkg/shell"
+)
+
+func TestTags(t *testing.T) {
+	tests := []struct {
+		name     string
+		metadata map[string]string
+		tags     string
+	}{
+		{
+			name: "empty",
+		},
+		{
+			name:     "values",
+			metadata: map[string]string{"safe": "0x1111", "nonce": "5"},
+			tags:     "nonce=5,safe=0x1111",
+		},
+		{
+			name:     "list",
+			metadata: map[string]string{"targets": "0x2222,0x3333", "safe": "0x1111"},
+			tags:     "safe=0x1111,targets=0x2222,targets=0x3333",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tags := formatTags(tt.metadata)
+			if tags != tt.tags {
+				t.Fatalf("expected tags %q, got %q", tt.tags, tags)
+			}
+			var split []string
+			if tags != "" {
+				split = strings.Split(tags, ",")
+			}
+			if got, want := FormatMetadata(parseTags(split)), FormatMetadata(tt.metadata); got != want {
+				t.Fatalf("expected metadata %q, got %q", want, got)
+			}
+		})
+	}
+}
+
+func TestOnePassword(t *testing.T) {
+	b64 := base64.StdEncoding.EncodeToString([]byte("{}"))
+	op := func(stdout string, args ...string) shell.Call {
+		return shell.Call{Name: "op", Args: args, Stdout: stdout}
+	}
+	notFound := op("", "--account", "my.1password.com", "read", "op://presigner/missing.json/text")
+	notFound.ExitCode = 1
+	notFound.Stderr = "[ERROR] could not read secret: \"missing.json\" isn't an item in the \"presigner\" vault\n"
+	r := &shell.Replayer{Calls: []shell.Call{
+		op("", "--account", "my.1password.com", "--vault", "presigner", "item", "create",
+			"--title", "tx.json", "--category", "Login", "--tags", "safe=0x1111,targets=0x2222,targets=0x3333", "text="+b64),
+		op("[{\"title\":\"tx.json \",\"tags\":[\"safe=0x1111\",\"targets=0x2222\",\"targets=0x3333\"]},{\"title\":\"other.json\"}]",
+			"--format", "json", "--account", "my.1password.com", "--vault", "presigner", "item", "list"),
+		op(b64+"\n", "--account", "my.1password.com", "read", "op://presigner/tx.json/text"),
+		notFound,
+		op("", "--account", "my.1password.com", "--vault", "presigner", "item", "edit", "tx.json", "--tags", "", "text="+b64),
+	}}
+	o := &OnePassword{Runner: r, Account: "my.1password.com", Vault: "presigner"}
+
+	if err := o.Put("tx.json", []byte("{}"), map[string]string{"safe": "0x1111", "targets": "0x2222,0x3333"}); err != nil {
+		t.Fatal(err)
+	}
+	items, err := o.List()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(items) != 2 || items[0].Name != "other.json" || items[1].Name != "tx.json" {
+		t.Fatalf("expected the sorted items, got %v", items)
+	}
+	if got := FormatMetadata(items[1].Metadata); got != "safe=0x1111 targets=0x2222,0x3333" {
+		t.Fatalf("expected the metadata put, got %s", got)
+	}
+	if !items[1].Matches(map[string]string{"targets": "0x3333"}) {
+		t.Fatal("expected the item to match one of its targets")
+	}
+
+	contents, err := o.Get("tx.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(contents) != "{}" {
+		t.Fatalf("expected the decoded item, got %q", contents)
+	}
+	if _, err := o.Get("missing.json"); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("expected ErrNotFound, got %v", err)
+	}
+
+	// replaced without metadata, the tags are removed
+	if err := o.Replace("tx.json", []byte("{}"), nil); err != nil {
+		t.Fatal(err)
+	}
+}
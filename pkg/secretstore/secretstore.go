@@ -0,0 +1,168 @@
+package secretstore
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/ethereum-optimism/presigner/pkg/shell"
+)
+
+// ErrNotFound is returned by Get when the item does not exist.
+var ErrNotFound = errors.New("item not found")
+
+// Backends.
+const (
+	BackendOnePassword = "1password"
+	BackendVault       = "vault"
+	BackendAge         = "age"
+)
+
+// Item is a stored item, with the metadata attached when it was put.
+type Item struct {
+	Name     string
+	Metadata map[string]string
+}
+
+// SecretStore stores the files of presigned transactions as named items.
+type SecretStore interface {
+	List() ([]Item, error)
+	Get(item string) ([]byte, error)
+	Put(item string, contents []byte, metadata map[string]string) error
+	// Replace overwrites an existing item in place, so it is never missing if it fails.
+	Replace(item string, contents []byte, metadata map[string]string) error
+	Delete(item string) error
+}
+
+// Matches returns true if the metadata of the item has all the given values,
+// values holding a comma separated list match any of their elements.
+func (i Item) Matches(filters map[string]string) bool {
+	for key, want := range filters {
+		found := false
+		for _, value := range strings.Split(i.Metadata[key], ",") {
+			if strings.EqualFold(value, want) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
+
+// FormatMetadata prints metadata as sorted key=value pairs.
+func FormatMetadata(metadata map[string]string) string {
+	keys := make([]string, 0, len(metadata))
+	for key := range metadata {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	pairs := make([]string, 0, len(keys))
+	for _, key := range keys {
+		pairs = append(pairs, key+"="+metadata[key])
+	}
+	return strings.Join(pairs, " ")
+}
+
+type OnePasswordConfig struct {
+	Account string `json:"account,omitempty"`
+	Vault   string `json:"vault,omitempty"`
+}
+
+type VaultConfig struct {
+	// address of the server, defaults to VAULT_ADDR, the token is read by the vault CLI
+	Address string `json:"address,omitempty"`
+	Mount   string `json:"mount,omitempty"`
+	Path    string `json:"path,omitempty"`
+}
+
+type AgeConfig struct {
+	Dir        string   `json:"dir,omitempty"`
+	Recipients []string `json:"recipients,omitempty"`
+	Identity   string   `json:"identity,omitempty"`
+}
+
+// Config selects and configures a backend, it can be read from a JSON file.
+type Config struct {
+	Backend     string            `json:"backend"`
+	OnePassword OnePasswordConfig `json:"1password"`
+	Vault       VaultConfig       `json:"vault"`
+	Age         AgeConfig         `json:"age"`
+}
+
+// DefaultConfig is the 1Password vault used before backends were configurable.
+func DefaultConfig() *Config {
+	return &Config{
+		Backend: BackendOnePassword,
+		OnePassword: OnePasswordConfig{
+			Account: "oplabs.1password.com",
+			Vault:   "Pre-signed Pause",
+		},
+		Vault: VaultConfig{
+			Mount: "secret",
+			Path:  "presigner",
+		},
+		Age: AgeConfig{
+			Dir: "vault",
+		},
+	}
+}
+
+// LoadConfig reads file over the default configuration.
+func LoadConfig(file string) (*Config, error) {
+	c := DefaultConfig()
+	contents, err := os.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(contents, c); err != nil {
+		return nil, fmt.Errorf("invalid config %s: %w", file, err)
+	}
+	return c, nil
+}
+
+// WithLocation returns a copy of the configuration using another location of the same
+// backend, i.e. a 1Password vault, a Vault path or an age directory.
+func (c *Config) WithLocation(location string) *Config {
+	other := *c
+	switch c.Backend {
+	case BackendOnePassword:
+		other.OnePassword.Vault = location
+	case BackendVault:
+		other.Vault.Path = location
+	case BackendAge:
+		other.Age.Dir = location
+	}
+	return &other
+}
+
+// Open returns the configured backend, running its CLI with r in workdir.
+func (c *Config) Open(r shell.Runner, workdir string) (SecretStore, error) {
+	switch c.Backend {
+	case BackendOnePassword:
+		return &OnePassword{Runner: r, Account: c.OnePassword.Account, Vault: c.OnePassword.Vault}, nil
+	case BackendVault:
+		return &Vault{Runner: r, Address: c.Vault.Address, Mount: c.Vault.Mount, Path: c.Vault.Path}, nil
+	case BackendAge:
+		if len(c.Age.Recipients) == 0 {
+			return nil, fmt.Errorf("age backend needs at least one recipient")
+		}
+		// the directory is relative to workdir, where age runs
+		dir := c.Age.Dir
+		if !filepath.IsAbs(dir) {
+			abs, err := filepath.Abs(filepath.Join(workdir, dir))
+			if err != nil {
+				return nil, err
+			}
+			dir = abs
+		}
+		return &AgeDir{Runner: r, Dir: dir, Recipients: c.Age.Recipients, Identity: c.Age.Identity}, nil
+	}
+	return nil, fmt.Errorf("unknown backend %s, use one of: %s, %s, %s", c.Backend, BackendOnePassword, BackendVault, BackendAge)
+}
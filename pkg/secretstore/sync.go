@@ -0,0 +1,194 @@
+package secretstore
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"fmt"
+	"log"
+	"os"
+	"path"
+	"regexp"
+	"sort"
+	"strconv"
+
+	"github.com/ethereum-optimism/presigner/pkg/envelope"
+)
+
+// versionExp matches the items holding previous versions of changed items.
+var versionExp = regexp.MustCompile(`^(.+)\.v(\d+)$`)
+
+// BaseName returns the name of the item a version was kept for, or name if it is not a version.
+func BaseName(name string) string {
+	if m := versionExp.FindStringSubmatch(name); m != nil {
+		return m[1]
+	}
+	return name
+}
+
+// SyncPlan lists the differences between a local directory and a secret store.
+type SyncPlan struct {
+	MissingRemotely []string
+	MissingLocally  []string
+	Changed         []string
+	Unchanged       []string
+
+	// highest version of every versioned item
+	versions map[string]int
+}
+
+// Empty returns true if there is nothing to apply.
+func (p *SyncPlan) Empty() bool {
+	return len(p.MissingRemotely) == 0 && len(p.MissingLocally) == 0 && len(p.Changed) == 0
+}
+
+// Print shows what Apply would do.
+func (p *SyncPlan) Print(version bool) {
+	for _, item := range p.MissingRemotely {
+		fmt.Printf("push       %s (new, missing remotely)\n", item)
+	}
+	for _, item := range p.MissingLocally {
+		fmt.Printf("pull       %s (missing locally)\n", item)
+	}
+	for _, item := range p.Changed {
+		if version {
+			fmt.Printf("version    %s (changed, remote kept as %s)\n", item, p.nextVersion(item))
+		} else {
+			fmt.Printf("overwrite  %s (changed)\n", item)
+		}
+	}
+	fmt.Printf("%d to push, %d to pull, %d changed, %d unchanged\n",
+		len(p.MissingRemotely), len(p.MissingLocally), len(p.Changed), len(p.Unchanged))
+}
+
+func (p *SyncPlan) nextVersion(item string) string {
+	return fmt.Sprintf("%s.v%d", item, p.versions[item]+1)
+}
+
+// Plan compares the files of dir with the items of the store by sha256 of their contents,
+// decrypted with env if either is encrypted.
+func Plan(store SecretStore, dir string, env *envelope.Envelope) (*SyncPlan, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	local := make(map[string]bool)
+	for _, entry := range entries {
+		if entry.Type().IsRegular() && entry.Name()[0] != '.' {
+			local[entry.Name()] = true
+		}
+	}
+
+	items, err := store.List()
+	if err != nil {
+		return nil, err
+	}
+	plan := &SyncPlan{versions: make(map[string]int)}
+	remote := make(map[string]bool)
+	for _, item := range items {
+		if m := versionExp.FindStringSubmatch(item.Name); m != nil {
+			n, _ := strconv.Atoi(m[2])
+			if n > plan.versions[m[1]] {
+				plan.versions[m[1]] = n
+			}
+			continue
+		}
+		remote[item.Name] = true
+		if !local[item.Name] {
+			plan.MissingLocally = append(plan.MissingLocally, item.Name)
+		}
+	}
+
+	for name := range local {
+		if !remote[name] {
+			plan.MissingRemotely = append(plan.MissingRemotely, name)
+			continue
+		}
+		localContents, err := os.ReadFile(path.Join(dir, name))
+		if err != nil {
+			return nil, err
+		}
+		localContents, err = env.Open(localContents)
+		if err != nil {
+			return nil, fmt.Errorf("decrypting %s: %w", name, err)
+		}
+		remoteContents, err := store.Get(name)
+		if err != nil {
+			return nil, fmt.Errorf("reading %s: %w", name, err)
+		}
+		remoteContents, err = env.Open(remoteContents)
+		if err != nil {
+			return nil, fmt.Errorf("decrypting item %s: %w", name, err)
+		}
+		localHash, remoteHash := sha256.Sum256(localContents), sha256.Sum256(remoteContents)
+		if bytes.Equal(localHash[:], remoteHash[:]) {
+			plan.Unchanged = append(plan.Unchanged, name)
+		} else {
+			plan.Changed = append(plan.Changed, name)
+		}
+	}
+
+	sort.Strings(plan.MissingRemotely)
+	sort.Strings(plan.MissingLocally)
+	sort.Strings(plan.Changed)
+	sort.Strings(plan.Unchanged)
+	return plan, nil
+}
+
+// Apply pushes the local files missing remotely, pulls the items missing locally,
+// and replaces changed items with the local file, keeping the remote one as
+// a new version if version is set. metadata returns the metadata of a pushed file.
+// Pushed files are encrypted with env if it is enabled, pulled items are written as stored.
+func (p *SyncPlan) Apply(store SecretStore, dir string, env *envelope.Envelope, version bool, metadata func(item string, contents []byte) map[string]string) error {
+	for _, item := range p.MissingRemotely {
+		contents, err := os.ReadFile(path.Join(dir, item))
+		if err != nil {
+			return err
+		}
+		sealed, err := env.Seal(contents)
+		if err != nil {
+			return fmt.Errorf("encrypting %s: %w", item, err)
+		}
+		if err := store.Put(item, sealed, metadata(item, contents)); err != nil {
+			return fmt.Errorf("pushing %s: %w", item, err)
+		}
+		log.Printf("pushed: %s\n", item)
+	}
+
+	for _, item := range p.MissingLocally {
+		contents, err := store.Get(item)
+		if err != nil {
+			return fmt.Errorf("pulling %s: %w", item, err)
+		}
+		if err := os.WriteFile(path.Join(dir, item), contents, 0600); err != nil {
+			return err
+		}
+		log.Printf("pulled: %s\n", item)
+	}
+
+	for _, item := range p.Changed {
+		if version {
+			previous, err := store.Get(item)
+			if err != nil {
+				return fmt.Errorf("reading %s: %w", item, err)
+			}
+			versioned := p.nextVersion(item)
+			if err := store.Put(versioned, previous, metadata(item, previous)); err != nil {
+				return fmt.Errorf("pushing %s: %w", versioned, err)
+			}
+			log.Printf("kept previous version of %s as %s\n", item, versioned)
+		}
+		contents, err := os.ReadFile(path.Join(dir, item))
+		if err != nil {
+			return err
+		}
+		sealed, err := env.Seal(contents)
+		if err != nil {
+			return fmt.Errorf("encrypting %s: %w", item, err)
+		}
+		if err := store.Replace(item, sealed, metadata(item, contents)); err != nil {
+			return fmt.Errorf("replacing %s: %w", item, err)
+		}
+		log.Printf("replaced: %s\n", item)
+	}
+	return nil
+}
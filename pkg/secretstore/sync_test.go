@@ -0,0 +1,235 @@
+package secretstore
+
+import (
+	"os"
+	"path"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// memoryStore keeps items in memory, with the names of the replaced items.
+type memoryStore struct {
+	items    map[string]string
+	metadata map[string]map[string]string
+	replaced []string
+}
+
+func newMemoryStore(items map[string]string) *memoryStore {
+	return &memoryStore{items: items, metadata: make(map[string]map[string]string)}
+}
+
+func (m *memoryStore) List() ([]Item, error) {
+	items := make([]Item, 0, len(m.items))
+	for name := range m.items {
+		items = append(items, Item{Name: name, Metadata: m.metadata[name]})
+	}
+	return items, nil
+}
+
+func (m *memoryStore) Get(item string) ([]byte, error) {
+	contents, ok := m.items[item]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	return []byte(contents), nil
+}
+
+func (m *memoryStore) Put(item string, contents []byte, metadata map[string]string) error {
+	m.items[item] = string(contents)
+	m.metadata[item] = metadata
+	return nil
+}
+
+func (m *memoryStore) Replace(item string, contents []byte, metadata map[string]string) error {
+	m.replaced = append(m.replaced, item)
+	return m.Put(item, contents, metadata)
+}
+
+func (m *memoryStore) Delete(item string) error {
+	if _, ok := m.items[item]; !ok {
+		return ErrNotFound
+	}
+	delete(m.items, item)
+	delete(m.metadata, item)
+	return nil
+}
+
+func writeFiles(t *testing.T, dir string, files map[string]string) {
+	t.Helper()
+	for name, contents := range files {
+		if err := os.WriteFile(path.Join(dir, name), []byte(contents), 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func readFile(t *testing.T, file string) string {
+	t.Helper()
+	contents, err := os.ReadFile(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(contents)
+}
+
+func TestPlan(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{
+		"new.json":       "new",
+		"same.json":      "same",
+		"changed.json":   "local",
+		".hidden.json":   "hidden",
+		"previous.v1.md": "not a version of a remote item",
+	})
+	if err := os.Mkdir(path.Join(dir, "subdir"), 0700); err != nil {
+		t.Fatal(err)
+	}
+	store := newMemoryStore(map[string]string{
+		"same.json":       "same",
+		"changed.json":    "remote",
+		"changed.json.v1": "first",
+		"changed.json.v3": "third",
+		"pulled.json":     "pulled",
+	})
+
+	plan, err := Plan(store, dir, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	check := func(kind string, got, want []string) {
+		t.Helper()
+		if strings.Join(got, ",") != strings.Join(want, ",") {
+			t.Fatalf("expected %s %v, got %v", kind, want, got)
+		}
+	}
+	check("missing remotely", plan.MissingRemotely, []string{"new.json", "previous.v1.md"})
+	check("missing locally", plan.MissingLocally, []string{"pulled.json"})
+	check("changed", plan.Changed, []string{"changed.json"})
+	check("unchanged", plan.Unchanged, []string{"same.json"})
+	if plan.Empty() {
+		t.Fatal("expected a plan with differences")
+	}
+	if got := plan.nextVersion("changed.json"); got != "changed.json.v4" {
+		t.Fatalf("expected the next version changed.json.v4, got %s", got)
+	}
+}
+
+func TestPlanEmpty(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{"same.json": "same"})
+	plan, err := Plan(newMemoryStore(map[string]string{"same.json": "same"}), dir, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !plan.Empty() {
+		t.Fatalf("expected an empty plan, got %+v", plan)
+	}
+}
+
+func TestApply(t *testing.T) {
+	tests := []struct {
+		name    string
+		version bool
+		want    map[string]string
+	}{
+		{
+			name: "overwrite",
+			want: map[string]string{
+				"new.json":     "new",
+				"changed.json": "local",
+				"pulled.json":  "pulled",
+			},
+		},
+		{
+			name:    "version",
+			version: true,
+			want: map[string]string{
+				"new.json":        "new",
+				"changed.json":    "local",
+				"changed.json.v1": "remote",
+				"pulled.json":     "pulled",
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			writeFiles(t, dir, map[string]string{"new.json": "new", "changed.json": "local"})
+			store := newMemoryStore(map[string]string{"changed.json": "remote", "pulled.json": "pulled"})
+			plan, err := Plan(store, dir, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			metadata := func(item string, contents []byte) map[string]string {
+				return map[string]string{"file": item, "size": strconv.Itoa(len(contents))}
+			}
+			if err := plan.Apply(store, dir, nil, tt.version, metadata); err != nil {
+				t.Fatal(err)
+			}
+			if len(store.items) != len(tt.want) {
+				t.Fatalf("expected items %v, got %v", tt.want, store.items)
+			}
+			for item, contents := range tt.want {
+				if store.items[item] != contents {
+					t.Fatalf("expected %s to hold %q, got %q", item, contents, store.items[item])
+				}
+			}
+			if strings.Join(store.replaced, ",") != "changed.json" {
+				t.Fatalf("expected changed.json to be replaced in place, got %v", store.replaced)
+			}
+			if got := FormatMetadata(store.metadata["changed.json"]); got != "file=changed.json size=5" {
+				t.Fatalf("expected the metadata of the local file, got %s", got)
+			}
+			if tt.version {
+				if got := FormatMetadata(store.metadata["changed.json.v1"]); got != "file=changed.json size=6" {
+					t.Fatalf("expected the metadata of the previous item, got %s", got)
+				}
+			}
+			if got := readFile(t, path.Join(dir, "pulled.json")); got != "pulled" {
+				t.Fatalf("expected the pulled item, got %q", got)
+			}
+
+			plan, err = Plan(store, dir, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !plan.Empty() {
+				t.Fatalf("expected nothing left to apply, got %+v", plan)
+			}
+		})
+	}
+}
+
+func TestItemMatches(t *testing.T) {
+	item := Item{Name: "tx.json", Metadata: map[string]string{"safe": "0xAbC", "chain": "1,10"}}
+	tests := []struct {
+		filters map[string]string
+		want    bool
+	}{
+		{filters: nil, want: true},
+		{filters: map[string]string{"safe": "0xabc"}, want: true},
+		{filters: map[string]string{"chain": "10"}, want: true},
+		{filters: map[string]string{"safe": "0xabc", "chain": "5"}, want: false},
+		{filters: map[string]string{"missing": "value"}, want: false},
+	}
+	for _, tt := range tests {
+		if got := item.Matches(tt.filters); got != tt.want {
+			t.Fatalf("expected %v for %v, got %v", tt.want, tt.filters, got)
+		}
+	}
+}
+
+func TestBaseName(t *testing.T) {
+	for name, want := range map[string]string{
+		"tx.json":     "tx.json",
+		"tx.json.v2":  "tx.json",
+		"tx.json.v":   "tx.json.v",
+		"tx.json.v2x": "tx.json.v2x",
+	} {
+		if got := BaseName(name); got != want {
+			t.Fatalf("expected base name %s of %s, got %s", want, name, got)
+		}
+	}
+}
@@ -0,0 +1,152 @@
+package secretstore
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/ethereum-optimism/presigner/pkg/shell"
+)
+
+// Vault stores items in a HashiCorp Vault KV v2 secrets engine with the vault CLI,
+// the contents are base64 encoded in the text key of the secret at Path/item,
+// and the metadata is stored as its custom metadata.
+// The CLI reads VAULT_TOKEN, and VAULT_ADDR unless Address is set,
+// e.g. from a dev server started with `vault server -dev`.
+type Vault struct {
+	Runner  shell.Runner
+	Address string
+	Mount   string
+	Path    string
+}
+
+func (v *Vault) env() []string {
+	if v.Address == "" {
+		return []string{}
+	}
+	return []string{"VAULT_ADDR=" + v.Address}
+}
+
+func (v *Vault) List() ([]Item, error) {
+	outBuffer, _, err := v.Runner.Run("vault", v.env(), "", true,
+		"kv", "list",
+		"-format=json",
+		"-mount="+v.Mount,
+		v.Path)
+	// listing an empty path is not an error
+	if notFound(err) {
+		return []Item{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var names []string
+	if err := json.Unmarshal(outBuffer, &names); err != nil {
+		return nil, fmt.Errorf("invalid list from vault: %w", err)
+	}
+	sort.Strings(names)
+
+	items := make([]Item, 0, len(names))
+	for _, name := range names {
+		metadata, err := v.metadata(name)
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, Item{Name: name, Metadata: metadata})
+	}
+	return items, nil
+}
+
+// notFound returns true if vault exited because there is no secret at the path,
+// vault exits with 2 for usage errors as well so stderr is checked.
+func notFound(err error) bool {
+	exitErr, ok := shell.IsExitError(err)
+	return ok && exitErr.ExitCode == 2 && strings.Contains(string(exitErr.Stderr), "No value found")
+}
+
+func (v *Vault) metadata(item string) (map[string]string, error) {
+	outBuffer, _, err := v.Runner.Run("vault", v.env(), "", true,
+		"kv", "metadata", "get",
+		"-format=json",
+		"-mount="+v.Mount,
+		v.Path+"/"+item)
+	if err != nil {
+		return nil, err
+	}
+	var j struct {
+		Data struct {
+			CustomMetadata map[string]string `json:"custom_metadata"`
+		} `json:"data"`
+	}
+	if err := json.Unmarshal(outBuffer, &j); err != nil {
+		return nil, fmt.Errorf("invalid metadata of %s from vault: %w", item, err)
+	}
+	return j.Data.CustomMetadata, nil
+}
+
+func (v *Vault) Get(item string) ([]byte, error) {
+	outBuffer, _, err := v.Runner.Run("vault", v.env(), "", true,
+		"kv", "get",
+		"-mount="+v.Mount,
+		"-field=text",
+		v.Path+"/"+item)
+	if notFound(err) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	decoded, err := base64.StdEncoding.DecodeString(strings.TrimSpace(string(outBuffer)))
+	if err != nil {
+		return nil, fmt.Errorf("error decoding base64: %w", err)
+	}
+	return decoded, nil
+}
+
+func (v *Vault) Put(item string, contents []byte, metadata map[string]string) error {
+	b64 := base64.StdEncoding.EncodeToString(contents)
+	// read the value from stdin, so it does not show in the process list
+	_, _, err := v.Runner.Run("vault", v.env(), b64, true,
+		"kv", "put",
+		"-mount="+v.Mount,
+		v.Path+"/"+item,
+		"text=-")
+	if err != nil {
+		return fmt.Errorf("error writing to vault: %w", err)
+	}
+
+	// always written, so an item replaced without metadata does not keep the previous one
+	args := []string{"kv", "metadata", "put", "-mount=" + v.Mount}
+	keys := make([]string, 0, len(metadata))
+	for key := range metadata {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		args = append(args, fmt.Sprintf("-custom-metadata=%s=%s", key, metadata[key]))
+	}
+	args = append(args, v.Path+"/"+item)
+	if _, _, err := v.Runner.Run("vault", v.env(), "", true, args...); err != nil {
+		return fmt.Errorf("error writing metadata to vault: %w", err)
+	}
+	return nil
+}
+
+// Replace writes a new version of the secret, kv put and the custom metadata overwrite the previous ones.
+func (v *Vault) Replace(item string, contents []byte, metadata map[string]string) error {
+	return v.Put(item, contents, metadata)
+}
+
+func (v *Vault) Delete(item string) error {
+	// delete the metadata, i.e. all the versions of the secret
+	_, _, err := v.Runner.Run("vault", v.env(), "", true,
+		"kv", "metadata", "delete",
+		"-mount="+v.Mount,
+		v.Path+"/"+item)
+	if err != nil {
+		return fmt.Errorf("error deleting from vault: %w", err)
+	}
+	return nil
+}
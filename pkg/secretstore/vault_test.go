@@ -0,0 +1,89 @@
+package secretstore
+
+import (
+	"encoding/base64"
+	"errors"
+	"testing"
+
+	"github.com/ethereum-optimism/presigner/pkg/shell"
+)
+
+const vaultAddr = "http://127.0.0.1:8200"
+
+func vaultCall(stdin, stdout string, args ...string) shell.Call {
+	return shell.Call{Name: "vault", Args: args, Env: []string{"VAULT_ADDR=" + vaultAddr}, Stdin: stdin, Stdout: stdout}
+}
+
+func vaultNotFound(args ...string) shell.Call {
+	call := vaultCall("", "", args...)
+	call.ExitCode = 2
+	call.Stderr = "No value found at secret/data/presigner\n"
+	return call
+}
+
+func TestVault(t *testing.T) {
+	b64 := base64.StdEncoding.EncodeToString([]byte("{}"))
+	r := &shell.Replayer{Calls: []shell.Call{
+		vaultNotFound("kv", "list", "-format=json", "-mount=secret", "presigner"),
+		vaultCall(b64, "", "kv", "put", "-mount=secret", "presigner/tx.json", "text=-"),
+		vaultCall("", "", "kv", "metadata", "put", "-mount=secret",
+			"-custom-metadata=nonce=5", "-custom-metadata=safe=0x1111", "presigner/tx.json"),
+		vaultCall("", "[\"tx.json\"]\n", "kv", "list", "-format=json", "-mount=secret", "presigner"),
+		vaultCall("", "{\"data\":{\"custom_metadata\":{\"nonce\":\"5\",\"safe\":\"0x1111\"}}}\n",
+			"kv", "metadata", "get", "-format=json", "-mount=secret", "presigner/tx.json"),
+		vaultCall("", b64+"\n", "kv", "get", "-mount=secret", "-field=text", "presigner/tx.json"),
+		vaultNotFound("kv", "get", "-mount=secret", "-field=text", "presigner/missing.json"),
+		vaultCall(b64, "", "kv", "put", "-mount=secret", "presigner/tx.json", "text=-"),
+		// the metadata is written even if empty, so the previous one is not kept
+		vaultCall("", "", "kv", "metadata", "put", "-mount=secret", "presigner/tx.json"),
+		vaultCall("", "", "kv", "metadata", "delete", "-mount=secret", "presigner/tx.json"),
+	}}
+	v := &Vault{Runner: r, Address: vaultAddr, Mount: "secret", Path: "presigner"}
+
+	items, err := v.List()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(items) != 0 {
+		t.Fatalf("expected no items, got %v", items)
+	}
+
+	if err := v.Put("tx.json", []byte("{}"), map[string]string{"safe": "0x1111", "nonce": "5"}); err != nil {
+		t.Fatal(err)
+	}
+	items, err = v.List()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(items) != 1 || items[0].Name != "tx.json" || FormatMetadata(items[0].Metadata) != "nonce=5 safe=0x1111" {
+		t.Fatalf("expected tx.json with its metadata, got %v", items)
+	}
+
+	contents, err := v.Get("tx.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(contents) != "{}" {
+		t.Fatalf("expected the decoded item, got %q", contents)
+	}
+	if _, err := v.Get("missing.json"); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("expected ErrNotFound, got %v", err)
+	}
+
+	if err := v.Replace("tx.json", []byte("{}"), nil); err != nil {
+		t.Fatal(err)
+	}
+	if err := v.Delete("tx.json"); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestVaultUsageError(t *testing.T) {
+	call := vaultCall("", "", "kv", "list", "-format=json", "-mount=secret", "presigner")
+	call.ExitCode = 2
+	call.Stderr = "permission denied\n"
+	v := &Vault{Runner: &shell.Replayer{Calls: []shell.Call{call}}, Address: vaultAddr, Mount: "secret", Path: "presigner"}
+	if _, err := v.List(); err == nil {
+		t.Fatal("expected an error")
+	}
+}
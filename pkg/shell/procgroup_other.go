@@ -0,0 +1,10 @@
+//go:build !unix
+
+package shell
+
+import (
+	"os/exec"
+)
+
+// setProcessGroup is a no-op without process groups, only cmd itself is killed.
+func setProcessGroup(cmd *exec.Cmd) {}
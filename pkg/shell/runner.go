@@ -0,0 +1,264 @@
+package shell
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"os/exec"
+	"slices"
+	"strings"
+)
+
+// Runner runs external commands, it is passed to the functions calling forge, cast, eip712sign,
+// age, op, vault or anvil so that they can run without them, see Recorder and Replayer.
+type Runner interface {
+	Run(name string, env []string, in string, silent bool, args ...string) ([]byte, []byte, error)
+	// RunTerminal runs commands that may prompt on the terminal, as the RunTerminal function
+	RunTerminal(name string, env []string, in string, silent bool, args ...string) ([]byte, []byte, error)
+	// Start runs a command in the background until it is stopped, e.g. a local node
+	Start(name string, args ...string) (Process, error)
+}
+
+// Process is a command started in the background by a Runner.
+type Process interface {
+	// Exited is closed if the command exits before it is stopped, Err then returns its error
+	Exited() <-chan struct{}
+	Err() error
+	Stop()
+}
+
+// Exec runs commands as subprocesses in Workdir, with Run and RunTerminal.
+type Exec struct {
+	Workdir string
+}
+
+func (e *Exec) Run(name string, env []string, in string, silent bool, args ...string) ([]byte, []byte, error) {
+	return Run(e.Workdir, name, env, in, silent, args...)
+}
+
+func (e *Exec) RunTerminal(name string, env []string, in string, silent bool, args ...string) ([]byte, []byte, error) {
+	return RunTerminal(e.Workdir, name, env, in, silent, args...)
+}
+
+// Start runs the command with the environment of the presigner, its stderr is shown.
+func (e *Exec) Start(name string, args ...string) (Process, error) {
+	cmd := exec.Command(name, args...)
+	cmd.Dir = e.Workdir
+	cmd.Env = os.Environ()
+	cmd.Stderr = os.Stderr
+	if err := cmd.Start(); err != nil {
+		return nil, err
+	}
+	p := &process{cmd: cmd, exited: make(chan struct{})}
+	go func() {
+		p.err = cmd.Wait()
+		close(p.exited)
+	}()
+	return p, nil
+}
+
+type process struct {
+	cmd    *exec.Cmd
+	exited chan struct{}
+	err    error
+}
+
+func (p *process) Exited() <-chan struct{} {
+	return p.exited
+}
+
+func (p *process) Err() error {
+	<-p.exited
+	return p.err
+}
+
+func (p *process) Stop() {
+	p.cmd.Process.Kill()
+	<-p.exited
+}
+
+// Call is a command run by a Recorder, with its outputs.
+type Call struct {
+	Name string `json:"name"`
+	// secrets are obfuscated, as in the logs
+	Args   []string `json:"args"`
+	Env    []string `json:"env,omitempty"`
+	Stdin  string   `json:"stdin,omitempty"`
+	Stdout string   `json:"stdout"`
+	Stderr string   `json:"stderr,omitempty"`
+
+	ExitCode int `json:"exit_code,omitempty"`
+	// error other than a non-zero exit code, e.g. a timeout
+	Error string `json:"error,omitempty"`
+
+	// started in the background with Start, the outputs are not recorded
+	Background bool `json:"background,omitempty"`
+}
+
+func (c *Call) String() string {
+	return strings.Join(append([]string{c.Name}, c.Args...), " ")
+}
+
+// Recorder runs commands with Runner, and saves them with their outputs to File after every call.
+type Recorder struct {
+	Runner Runner
+	File   string
+
+	calls []Call
+}
+
+func (r *Recorder) Run(name string, env []string, in string, silent bool, args ...string) ([]byte, []byte, error) {
+	outBuffer, errBuffer, err := r.Runner.Run(name, env, in, silent, args...)
+	r.record(name, env, in, args, outBuffer, errBuffer, err)
+	return outBuffer, errBuffer, err
+}
+
+func (r *Recorder) RunTerminal(name string, env []string, in string, silent bool, args ...string) ([]byte, []byte, error) {
+	outBuffer, errBuffer, err := r.Runner.RunTerminal(name, env, in, silent, args...)
+	r.record(name, env, in, args, outBuffer, errBuffer, err)
+	return outBuffer, errBuffer, err
+}
+
+func (r *Recorder) Start(name string, args ...string) (Process, error) {
+	p, err := r.Runner.Start(name, args...)
+	call := Call{Name: name, Args: ObfuscateArgs(args), Background: true}
+	if err != nil {
+		call.Error = err.Error()
+	}
+	r.save(call)
+	return p, err
+}
+
+func (r *Recorder) record(name string, env []string, in string, args []string, outBuffer, errBuffer []byte, err error) {
+	call := Call{
+		Name:   name,
+		Args:   ObfuscateArgs(args),
+		Env:    env,
+		Stdin:  in,
+		Stdout: string(outBuffer),
+		Stderr: string(errBuffer),
+	}
+	if exitErr, ok := IsExitError(err); ok {
+		call.ExitCode = exitErr.ExitCode
+	} else if err != nil {
+		call.Error = err.Error()
+	}
+	r.save(call)
+}
+
+func (r *Recorder) save(call Call) {
+	r.calls = append(r.calls, call)
+
+	// saved every time, the presigner may exit after any command
+	jsonContents, jsonErr := json.MarshalIndent(r.calls, "", "  ")
+	if jsonErr == nil {
+		jsonErr = os.WriteFile(r.File, jsonContents, 0600)
+	}
+	if jsonErr != nil {
+		log.Printf("error saving recorded commands: %v\n", jsonErr)
+	}
+}
+
+// Replayer returns the outputs of the calls saved by a Recorder instead of running the commands.
+// They must be run in the recorded order, with the same arguments, environment and input.
+type Replayer struct {
+	Calls []Call
+
+	next int
+}
+
+// LoadReplayer reads the calls saved by a Recorder.
+func LoadReplayer(file string) (*Replayer, error) {
+	jsonContents, err := os.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	var calls []Call
+	if err := json.Unmarshal(jsonContents, &calls); err != nil {
+		return nil, fmt.Errorf("invalid recorded commands: %w", err)
+	}
+	return &Replayer{Calls: calls}, nil
+}
+
+func (r *Replayer) Run(name string, env []string, in string, silent bool, args ...string) ([]byte, []byte, error) {
+	call, err := r.nextCall(name, args, false)
+	if err != nil {
+		return nil, nil, err
+	}
+	if !slices.Equal(call.Env, env) || call.Stdin != in {
+		return nil, nil, fmt.Errorf("unexpected environment or input for %s", call.String())
+	}
+	r.next++
+
+	if !silent {
+		fmt.Println("replaying:", call.String())
+		fmt.Fprint(os.Stdout, call.Stdout)
+		fmt.Fprint(os.Stderr, call.Stderr)
+	}
+	if call.ExitCode != 0 {
+		err = &ExitError{Command: call.String(), ExitCode: call.ExitCode, Stderr: []byte(call.Stderr)}
+	} else if call.Error != "" {
+		err = errors.New(call.Error)
+	}
+	return []byte(call.Stdout), []byte(call.Stderr), err
+}
+
+func (r *Replayer) RunTerminal(name string, env []string, in string, silent bool, args ...string) ([]byte, []byte, error) {
+	return r.Run(name, env, in, silent, args...)
+}
+
+// Start returns a process that runs until stopped, the commands sent to it are replayed as well.
+func (r *Replayer) Start(name string, args ...string) (Process, error) {
+	call, err := r.nextCall(name, args, true)
+	if err != nil {
+		return nil, err
+	}
+	r.next++
+	if call.Error != "" {
+		return nil, errors.New(call.Error)
+	}
+	return &replayedProcess{exited: make(chan struct{})}, nil
+}
+
+// nextCall returns the next recorded call, if it is the given command.
+func (r *Replayer) nextCall(name string, args []string, background bool) (*Call, error) {
+	actual := Call{Name: name, Args: ObfuscateArgs(args)}
+	if r.next >= len(r.Calls) {
+		return nil, fmt.Errorf("unexpected command %s, the %d recorded commands were replayed", actual.String(), len(r.Calls))
+	}
+	call := &r.Calls[r.next]
+	if call.Name != name || !slices.Equal(call.Args, actual.Args) || call.Background != background {
+		return nil, fmt.Errorf("unexpected command %s, expected %s", actual.String(), call.String())
+	}
+	return call, nil
+}
+
+type replayedProcess struct {
+	exited chan struct{}
+}
+
+func (p *replayedProcess) Exited() <-chan struct{} {
+	return p.exited
+}
+
+func (p *replayedProcess) Err() error {
+	return nil
+}
+
+func (p *replayedProcess) Stop() {}
+
+// ObfuscateArgs hides the secrets of command line arguments, as ObfuscateCmdString.
+func ObfuscateArgs(args []string) []string {
+	obfuscated := make([]string, len(args))
+	for i, arg := range args {
+		if i > 0 && (strings.HasSuffix(args[i-1], "-private-key") ||
+			strings.HasSuffix(args[i-1], "-mnemonic") ||
+			strings.HasSuffix(args[i-1], "-hd-paths")) {
+			arg = "********"
+		}
+		obfuscated[i] = arg
+	}
+	return obfuscated
+}
@@ -3,17 +3,110 @@ package shell
 import (
 	"bufio"
 	"bytes"
+	"context"
+	"errors"
 	"fmt"
 	"io"
 	"log"
 	"os"
 	"os/exec"
+	"os/signal"
 	"strings"
+	"sync/atomic"
+	"syscall"
+	"time"
 )
 
+// ExitError is returned by Run when the command started but exited with a non-zero code.
+type ExitError struct {
+	// command line, with the secrets obfuscated
+	Command  string
+	ExitCode int
+	Stderr   []byte
+}
+
+func (e *ExitError) Error() string {
+	msg := fmt.Sprintf("%s exited with code %d", e.Command, e.ExitCode)
+	// the last line of stderr is usually the error
+	lines := strings.Split(strings.TrimSpace(string(e.Stderr)), "\n")
+	if last := strings.TrimSpace(lines[len(lines)-1]); last != "" {
+		msg += ": " + last
+	}
+	return msg
+}
+
+// TimeoutError is returned by Run when a command is killed for running longer than its timeout.
+type TimeoutError struct {
+	Command string
+	Timeout time.Duration
+}
+
+func (e *TimeoutError) Error() string {
+	return fmt.Sprintf("%s timed out after %s", e.Command, e.Timeout)
+}
+
+// AbortError is returned by Run when a command is killed because the user interrupted the presigner.
+type AbortError struct {
+	Command string
+}
+
+func (e *AbortError) Error() string {
+	return fmt.Sprintf("%s aborted by the user", e.Command)
+}
+
+// Timeouts bounds the run time of commands by name, e.g. "forge", commands not listed run until they exit.
+var Timeouts = make(map[string]time.Duration)
+
+var (
+	interrupted, interrupt = context.WithCancel(context.Background())
+	running                atomic.Int32
+)
+
+// HandleInterrupt kills the running commands on SIGINT or SIGTERM, which then fail with an *AbortError,
+// or exits right away if none is running. A second signal terminates the process.
+func HandleInterrupt() {
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+	go func() {
+		<-signals
+		signal.Stop(signals)
+		interrupt()
+		if running.Load() == 0 {
+			log.Println("aborted by the user")
+			os.Exit(130)
+		}
+	}()
+}
+
+// Run runs a command and returns its stdout and stderr. The error is an *ExitError
+// if the command exited with a non-zero code, the output is returned in any case.
+// The command runs in its own process group, killed with its children on timeout or interrupt.
 func Run(workdir, name string, env []string, in string, silent bool, args ...string) ([]byte, []byte, error) {
-	cmd := exec.Command(name, args...)
+	return run(false, workdir, name, env, in, silent, args...)
+}
+
+// RunTerminal runs a command that may prompt on the terminal, e.g. for a passphrase,
+// in the process group of the presigner so it can read the terminal and gets its interrupts.
+func RunTerminal(workdir, name string, env []string, in string, silent bool, args ...string) ([]byte, []byte, error) {
+	return run(true, workdir, name, env, in, silent, args...)
+}
+
+func run(terminal bool, workdir, name string, env []string, in string, silent bool, args ...string) ([]byte, []byte, error) {
+	ctx := interrupted
+	timeout := Timeouts[name]
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+
+	cmd := exec.CommandContext(ctx, name, args...)
 	cmd.Dir = workdir
+	if !terminal {
+		setProcessGroup(cmd)
+	}
+	// do not wait forever for children holding the output open once killed
+	cmd.WaitDelay = 5 * time.Second
 
 	cmd.Env = os.Environ()
 	if len(env) > 0 {
@@ -42,18 +135,53 @@ func Run(workdir, name string, env []string, in string, silent bool, args ...str
 	if !silent {
 		fmt.Println("running:", ObfuscateCmdString(cmd.String()))
 	}
-	err := cmd.Start()
+	running.Add(1)
+	defer running.Add(-1)
+	if err := cmd.Start(); err != nil {
+		if ctxErr := contextError(ctx, cmd, timeout); ctxErr != nil {
+			return nil, nil, ctxErr
+		}
+		return nil, nil, err
+	}
 
 	if in != "" {
 		io.WriteString(stdinpipe, in)
 		stdinpipe.Close()
 	}
 
-	cmd.Wait()
-
+	err := cmd.Wait()
+	if ctxErr := contextError(ctx, cmd, timeout); ctxErr != nil {
+		return outBuffer.Bytes(), errBuffer.Bytes(), ctxErr
+	}
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		err = &ExitError{
+			Command:  ObfuscateCmdString(cmd.String()),
+			ExitCode: exitErr.ExitCode(),
+			Stderr:   errBuffer.Bytes(),
+		}
+	}
 	return outBuffer.Bytes(), errBuffer.Bytes(), err
 }
 
+// contextError tells why the command was killed, if it was.
+func contextError(ctx context.Context, cmd *exec.Cmd, timeout time.Duration) error {
+	if interrupted.Err() != nil {
+		return &AbortError{Command: ObfuscateCmdString(cmd.String())}
+	}
+	if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		return &TimeoutError{Command: ObfuscateCmdString(cmd.String()), Timeout: timeout}
+	}
+	return nil
+}
+
+// IsExitError returns the *ExitError of err, if the command exited with a non-zero code.
+func IsExitError(err error) (*ExitError, bool) {
+	var exitErr *ExitError
+	ok := errors.As(err, &exitErr)
+	return exitErr, ok
+}
+
 func ObfuscateCmdString(s string) string {
 	output := ""
 	words := strings.Split(s, " ")
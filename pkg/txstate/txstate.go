@@ -0,0 +1,170 @@
+package txstate
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/ethereum-optimism/presigner/pkg/multicall"
+	"github.com/ethereum-optimism/presigner/pkg/safe"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+)
+
+// Signature types, ECDSA signatures produced by eip712sign have no type.
+const (
+	SignatureApprovedHash = "approved_hash"
+
+	// personal_sign signature of the safe transaction hash, stored with v + 4
+	SignatureEthSign = "eth_sign"
+
+	// EIP-1271 signature of an owner contract, Signature holds the bytes
+	// passed to isValidSignature, appended after the static part when assembled
+	SignatureContract = "contract"
+)
+
+type TxSignature struct {
+	Signer    string `json:"signer"`
+	Signature string `json:"signature"`
+	Type      string `json:"type,omitempty"`
+}
+
+type TxState struct {
+	ChainId    string `json:"chain_id"`
+	RpcUrl     string `json:"rpc_url"`
+	CreatedAt  string `json:"created_at"`
+	SafeAddr   string `json:"safe_addr"`
+	SafeNonce  string `json:"safe_nonce"`
+	TargetAddr string `json:"target_addr"`
+	ScriptName string `json:"script_name"`
+
+	// set when the transaction calls more than one target, TargetAddr is the first one
+	TargetAddrs []string `json:"target_addrs,omitempty"`
+
+	// environment parameters of the script, as described by the script registry
+	Params map[string]string `json:"params,omitempty"`
+
+	// safe operation, call or delegatecall, and the address the safe sends it to
+	Operation string `json:"operation,omitempty"`
+	To        string `json:"to,omitempty"`
+
+	// set for CallGeneric, encoded as the IMulticall3.Call3[] executed by the safe
+	Calls []multicall.Call `json:"calls,omitempty"`
+
+	// populated by sign
+	Data       string        `json:"data"`
+	Signatures []TxSignature `json:"signatures,omitempty"`
+
+	// populated by simulate
+	Calldata string `json:"calldata,omitempty"`
+
+	// populated by execute once the effect of the transaction is verified
+	ExecutedAt string `json:"executed_at,omitempty"`
+}
+
+// SafeOperation returns the operation and its destination, files created
+// before they were stored are MultisigBuilder delegatecalls to Multicall3.
+func (tx *TxState) SafeOperation() (string, string) {
+	if tx.Operation == "" {
+		return safe.OperationDelegateCall, safe.Multicall3Address
+	}
+	return tx.Operation, tx.To
+}
+
+// Targets returns all the addresses called by the transaction.
+func (tx *TxState) Targets() []string {
+	if len(tx.TargetAddrs) > 0 {
+		return tx.TargetAddrs
+	}
+	return []string{tx.TargetAddr}
+}
+
+// Parse decodes the JSON file of a transaction.
+func Parse(contents []byte) (*TxState, error) {
+	var tx TxState
+	if err := json.Unmarshal(contents, &tx); err != nil {
+		return nil, err
+	}
+	return &tx, nil
+}
+
+// Metadata keys attached to the files of a transaction pushed to a secret store.
+const (
+	MetadataChain   = "chain"
+	MetadataSafe    = "safe"
+	MetadataTarget  = "target"
+	MetadataNonce   = "nonce"
+	MetadataScript  = "script"
+	MetadataSigners = "signers"
+)
+
+// Metadata describes the transaction without its data or signatures, empty fields are left out.
+func (tx *TxState) Metadata() map[string]string {
+	metadata := map[string]string{
+		MetadataChain:   tx.ChainId,
+		MetadataSafe:    strings.ToLower(tx.SafeAddr),
+		MetadataTarget:  strings.ToLower(strings.Join(tx.Targets(), ",")),
+		MetadataNonce:   tx.SafeNonce,
+		MetadataScript:  tx.ScriptName,
+		MetadataSigners: strconv.Itoa(len(tx.Signatures)),
+	}
+	for key, value := range metadata {
+		if value == "" {
+			delete(metadata, key)
+		}
+	}
+	return metadata
+}
+
+// Validate checks the fields of a transaction file, e.g. one that has been
+// pulled from a secret store, without reaching the network.
+func (tx *TxState) Validate() error {
+	if _, err := strconv.ParseUint(tx.ChainId, 10, 64); err != nil {
+		return fmt.Errorf("invalid chain_id %q", tx.ChainId)
+	}
+	if !common.IsHexAddress(tx.SafeAddr) {
+		return fmt.Errorf("invalid safe_addr %q", tx.SafeAddr)
+	}
+	if _, err := strconv.ParseUint(tx.SafeNonce, 10, 64); err != nil {
+		return fmt.Errorf("invalid safe_nonce %q", tx.SafeNonce)
+	}
+	if tx.ScriptName == "" {
+		return fmt.Errorf("missing script_name")
+	}
+	for _, target := range tx.TargetAddrs {
+		if !common.IsHexAddress(target) {
+			return fmt.Errorf("invalid target_addrs entry %q", target)
+		}
+	}
+	if tx.TargetAddr != "" && !common.IsHexAddress(tx.TargetAddr) {
+		return fmt.Errorf("invalid target_addr %q", tx.TargetAddr)
+	}
+	if tx.Data != "" && !strings.HasPrefix(tx.Data, "0x1901") {
+		return fmt.Errorf("data is not EIP-712 encoded")
+	}
+	if tx.Data != "" {
+		if _, err := hexutil.Decode(tx.Data); err != nil {
+			return fmt.Errorf("invalid data: %w", err)
+		}
+	}
+	for _, s := range tx.Signatures {
+		if !common.IsHexAddress(s.Signer) {
+			return fmt.Errorf("invalid signer %q", s.Signer)
+		}
+		sig, err := hex.DecodeString(strings.TrimPrefix(s.Signature, "0x"))
+		if err != nil {
+			return fmt.Errorf("invalid signature of %s: %w", s.Signer, err)
+		}
+		if s.Type != SignatureContract && len(sig) != 65 {
+			return fmt.Errorf("invalid signature of %s: %d bytes", s.Signer, len(sig))
+		}
+	}
+	if tx.Calldata != "" {
+		if _, err := safe.DecodeExecTransaction(tx.Calldata); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -1,41 +1,47 @@
 package main
 
 import (
-	"encoding/base64"
+	"encoding/hex"
 	"encoding/json"
+	"errors"
 	"flag"
 	"fmt"
+	"io"
+	"io/fs"
 	"log"
 	"os"
 	"path"
 	"regexp"
+	"sort"
+	"strconv"
 	"strings"
 	"time"
 
+	"github.com/ethereum-optimism/presigner/pkg/anvil"
+	"github.com/ethereum-optimism/presigner/pkg/cast"
+	"github.com/ethereum-optimism/presigner/pkg/envelope"
+	"github.com/ethereum-optimism/presigner/pkg/multicall"
+	"github.com/ethereum-optimism/presigner/pkg/oneliner"
+	"github.com/ethereum-optimism/presigner/pkg/registry"
+	"github.com/ethereum-optimism/presigner/pkg/safe"
+	"github.com/ethereum-optimism/presigner/pkg/scaffold"
 	"github.com/ethereum-optimism/presigner/pkg/shell"
+	"github.com/ethereum-optimism/presigner/pkg/txstate"
 	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/crypto"
 )
 
-type TxSignature struct {
-	Signer    string `json:"signer"`
-	Signature string `json:"signature"`
-}
-
-type TxState struct {
-	ChainId    string `json:"chain_id"`
-	RpcUrl     string `json:"rpc_url"`
-	CreatedAt  string `json:"created_at"`
-	SafeAddr   string `json:"safe_addr"`
-	SafeNonce  string `json:"safe_nonce"`
-	TargetAddr string `json:"target_addr"`
-	ScriptName string `json:"script_name"`
+// stringList is a flag that can be repeated.
+type stringList []string
 
-	// populated by sign
-	Data       string `json:"data"`
-	Signatures []TxSignature `json:"signatures,omitempty"`
+func (l *stringList) String() string {
+	return strings.Join(*l, ", ")
+}
 
-	// populated by simulate
-	Calldata string `json:"calldata,omitempty"`
+func (l *stringList) Set(value string) error {
+	*l = append(*l, value)
+	return nil
 }
 
 func main() {
@@ -48,18 +54,68 @@ func main() {
 	flag.StringVar(&workdir, "workdir", ".", "Directory in which to run the subprocess")
 	flag.StringVar(&scriptName, "script-name", "CallPause", "Script name")
 
+	var jsonOutput bool
+	flag.BoolVar(&jsonOutput, "json", false, "Print failures as a JSON error object on stdout")
+
+	var recordFile string
+	var replayFile string
+	flag.StringVar(&recordFile, "record", "", "Record the forge, cast and eip712sign commands run and their outputs to a JSON file")
+	flag.StringVar(&replayFile, "replay", "", "Replay the commands recorded with --record instead of running them")
+
+	// encryption flags
+	var encryptTo string
+	var encryptPassphrase bool
+	var ageIdentity string
+	flag.StringVar(&encryptTo, "encrypt-to", "", "Comma separated list of age recipients to encrypt the transaction files to")
+	flag.BoolVar(&encryptPassphrase, "encrypt-passphrase", false, "Encrypt the transaction files with a passphrase")
+	flag.StringVar(&ageIdentity, "age-identity", "", "age identity file to decrypt the transaction files with")
+
+	// timeout flags, 0 for none
+	var forgeTimeout time.Duration
+	var castTimeout time.Duration
+	var signTimeout time.Duration
+	flag.DurationVar(&forgeTimeout, "forge-timeout", 15*time.Minute, "Timeout of forge scripts, including compilation")
+	flag.DurationVar(&castTimeout, "cast-timeout", 2*time.Minute, "Timeout of cast calls and transactions")
+	flag.DurationVar(&signTimeout, "sign-timeout", 5*time.Minute, "Timeout of eip712sign, including waiting for approval on the ledger")
+
 	// create flags
 	var chainId string
 	var rpcUrl string
 	var safeAddr string
 	var safeNonce string
 	var targetAddr string
+	var targetsFile string
+	var callSpecs stringList
+	var callsFile string
+	var pauseIdentifier string
+	var paramSpecs stringList
 
 	flag.StringVar(&chainId, "chain", "1", "Chain ID")
 	flag.StringVar(&rpcUrl, "rpc-url", "", "RPC URL (default to \"https://eth.llamarpc.com)\"")
 	flag.StringVar(&safeAddr, "safe-addr", "", "Safe address")
 	flag.StringVar(&safeNonce, "safe-nonce", "", "Safe nonce")
-	flag.StringVar(&targetAddr, "target-addr", "", "Target address")
+	flag.StringVar(&targetAddr, "target-addr", "", "Target address, or comma separated list of target addresses")
+	flag.StringVar(&targetsFile, "targets-file", "", "File with one target address per line")
+	flag.Var(&callSpecs, "call", "Call to execute with CallGeneric as target:signature[:arg...], can be repeated")
+	flag.StringVar(&callsFile, "calls-file", "", "JSON file with the calls to execute with CallGeneric")
+	flag.StringVar(&pauseIdentifier, "pause-identifier", "", "Identifier emitted by the Paused event of CallPause, same as --param PAUSE_IDENTIFIER=...")
+	flag.Var(&paramSpecs, "param", "Script parameter as NAME=VALUE, can be repeated")
+
+	// policy flags
+	var delegatecallAllowlist string
+	flag.StringVar(&delegatecallAllowlist, "delegatecall-allowlist", safe.Multicall3Address, "Comma separated list of addresses the safe may delegatecall")
+
+	// pause-status flags
+	var fromBlock string
+	flag.StringVar(&fromBlock, "from-block", "earliest", "First block to search for Paused/Unpaused events")
+
+	// new-script flags
+	var newScriptName string
+	var abiFile string
+	var functionName string
+	flag.StringVar(&newScriptName, "name", "", "Name of the script generated by new-script, e.g. CallFoo")
+	flag.StringVar(&abiFile, "abi", "", "ABI or forge artifact of the contract called by the script generated by new-script")
+	flag.StringVar(&functionName, "function", "", "Function called by the script generated by new-script")
 
 	// sign flags
 	var privateKey string
@@ -73,179 +129,138 @@ func main() {
 	flag.StringVar(&hdPath, "hd-paths", "m/44'/60'/0'/0/0", "Hierarchical deterministic derivation path for mnemonic or ledger, for signing or executing")
 	flag.StringVar(&senderAddr, "sender", "", "Address of the --sender to pass to forge")
 
+	// add-signature flags
+	var signerAddr string
+	var signatureHex string
+	var contractSignature bool
+	var ethSign bool
+	flag.StringVar(&signerAddr, "signer", "", "Owner that produced the signature added by add-signature")
+	flag.StringVar(&signatureHex, "signature", "", "Hex encoded signature added by add-signature")
+	flag.BoolVar(&ethSign, "eth-sign", false, "The signature added by add-signature is a personal_sign signature of the safe transaction hash")
+	flag.BoolVar(&contractSignature, "contract-signature", false, "The signature added by add-signature is an EIP-1271 signature of an owner contract")
+
+	// simulate flags
+	var useAnvil bool
+	var anvilPort string
+	flag.BoolVar(&useAnvil, "anvil", false, "Also execute the signed transaction on a local anvil fork and report state diff and events")
+	flag.StringVar(&anvilPort, "anvil-port", "8545", "Port for the local anvil fork")
 
 	flag.Parse()
 
-	args := flag.Args()
-
-	if len(args) == 0 {
-		log.Println("no command specified, use one of: create, nonce, threshold, owners, sign, merge, verify, simulate, execute")
-		flag.PrintDefaults()
-		os.Exit(1)
-	}
-	cmd := args[0]
-
-	if cmd == "nonce" {
-		if safeAddr == "" {
-			log.Println("missing one of the required nonce parameter: safe-addr")
-			flag.PrintDefaults()
-			os.Exit(1)
-		}
-
-		if rpcUrl == "" {
-			rpcUrl = "https://eth.llamarpc.com"
-		}
-		if chainId == "" {
-			chainId = "1"
-		}
+	shell.Timeouts["forge"] = forgeTimeout
+	shell.Timeouts["cast"] = castTimeout
+	shell.Timeouts["eip712sign"] = signTimeout
+	shell.HandleInterrupt()
 
-		env := []string{
-			"SAFE_ADDR=" + safeAddr,
-		}
-		outBuffer, _, err := shell.Run(workdir, "cast", env, "", true,
-			"call",
-			safeAddr,
-			"nonce()",
-			"--rpc-url", rpcUrl)
+	var r shell.Runner = &shell.Exec{Workdir: workdir}
+	if replayFile != "" {
+		replayer, err := shell.LoadReplayer(replayFile)
 		if err != nil {
-			log.Printf("error running forge: %v\n", err)
+			log.Printf("error reading recorded commands: %v\n", err)
 			os.Exit(1)
 		}
+		r = replayer
+	}
+	if recordFile != "" {
+		r = &shell.Recorder{Runner: r, File: recordFile}
+	}
 
-		outBuffer, _, err = shell.Run(workdir, "cast", env, string(outBuffer), true,
-			"--to-dec")
-		fmt.Println(strings.TrimSpace(string(outBuffer)))
+	// with --json only the failure is printed on stdout, the output of the commands goes to stderr
+	var jsonOut io.Writer
+	if jsonOutput {
+		jsonOut = os.Stdout
+		os.Stdout = os.Stderr
+	}
 
-	} else if cmd == "threshold" {
-		if safeAddr == "" {
-			log.Println("missing one of the required nonce parameter: safe-addr")
-			flag.PrintDefaults()
-			os.Exit(1)
-		}
+	// age is never recorded nor replayed, its input or output is the decrypted transaction
+	fileEnvelope := &envelope.Envelope{
+		Runner:     &shell.Exec{Workdir: workdir},
+		Passphrase: encryptPassphrase,
+		Identity:   ageIdentity,
+	}
+	if encryptTo != "" {
+		fileEnvelope.Recipients = strings.Split(encryptTo, ",")
+	}
 
-		if rpcUrl == "" {
-			rpcUrl = "https://eth.llamarpc.com"
-		}
-		if chainId == "" {
-			chainId = "1"
-		}
+	f := &cmdFlags{
+		workdir:               workdir,
+		jsonFile:              jsonFile,
+		scriptName:            scriptName,
+		jsonOut:               jsonOut,
+		envelope:              fileEnvelope,
+		chainId:               chainId,
+		rpcUrl:                rpcUrl,
+		safeAddr:              safeAddr,
+		safeNonce:             safeNonce,
+		targetAddr:            targetAddr,
+		targetsFile:           targetsFile,
+		callSpecs:             callSpecs,
+		callsFile:             callsFile,
+		pauseIdentifier:       pauseIdentifier,
+		paramSpecs:            paramSpecs,
+		delegatecallAllowlist: delegatecallAllowlist,
+		fromBlock:             fromBlock,
+		newScriptName:         newScriptName,
+		abiFile:               abiFile,
+		functionName:          functionName,
+		privateKey:            privateKey,
+		ledger:                ledger,
+		mnemonic:              mnemonic,
+		hdPath:                hdPath,
+		senderAddr:            senderAddr,
+		signerAddr:            signerAddr,
+		signatureHex:          signatureHex,
+		contractSignature:     contractSignature,
+		ethSign:               ethSign,
+		useAnvil:              useAnvil,
+		anvilPort:             anvilPort,
+	}
 
-		env := []string{
-			"SAFE_ADDR=" + safeAddr,
-		}
-		outBuffer, _, err := shell.Run(workdir, "cast", env, "", true,
-			"call",
-			safeAddr,
-			"getThreshold()",
-			"--rpc-url", rpcUrl)
-		if err != nil {
-			log.Printf("error running forge: %v\n", err)
-			os.Exit(1)
-		}
+	args := flag.Args()
 
-		outBuffer, _, err = shell.Run(workdir, "cast", env, string(outBuffer), true,
-			"--to-dec")
-		fmt.Println(strings.TrimSpace(string(outBuffer)))
-	} else if cmd == "owners" {
+	if len(args) == 0 {
+		log.Println("no command specified, use one of: create, new-script, nonce, threshold, owners, pause-status, sign, approve, add-signature, merge, decode, verify, simulate, execute")
+		flag.PrintDefaults()
+		os.Exit(1)
+	}
+	cmd := args[0]
 
+	if cmd == "nonce" || cmd == "threshold" || cmd == "owners" {
 		if safeAddr == "" {
-			log.Println("missing one of the required nonce parameter: safe-addr")
+			log.Printf("missing one of the required %s parameter: safe-addr\n", cmd)
 			flag.PrintDefaults()
 			os.Exit(1)
 		}
-
-		if rpcUrl == "" {
-			rpcUrl = "https://eth.llamarpc.com"
-		}
-		if chainId == "" {
-			chainId = "1"
-		}
-
-		env := []string{
-			"SAFE_ADDR=" + safeAddr,
-		}
-		outBuffer, _, err := shell.Run(workdir, "cast", env, "", true,
-			"call",
-			safeAddr,
-			"getOwners()",
-			"--rpc-url", rpcUrl)
-		if err != nil {
-			log.Printf("error running forge: %v\n", err)
-			os.Exit(1)
-		}
-
-		out := strings.TrimSpace(string(outBuffer))
-
-		if !strings.HasPrefix(out, "0x") {
-			log.Println("error running forge: result has invalid format")
+		exitOnError(printSafeState(r, f, cmd))
+	} else if cmd == "pause-status" {
+		if targetAddr == "" {
+			log.Println("missing one of the required pause-status parameter: target-addr")
+			flag.PrintDefaults()
 			os.Exit(1)
 		}
-
-		hex := out[2:]
-		if len(hex)%64 != 0 {
-			log.Println("error running forge: result has invalid format")
+		exitOnError(pauseStatus(r, f))
+	} else if cmd == "new-script" {
+		if newScriptName == "" || abiFile == "" || functionName == "" {
+			log.Println("missing one of the required new-script parameter: name, abi, function")
+			flag.PrintDefaults()
 			os.Exit(1)
 		}
-
-		// skip first two 64-byte chunks
-		for i := 2 * 64; i < len(hex); i += 64 {
-			addr := common.HexToAddress(hex[i : i+64])
-			fmt.Println(strings.ToLower(addr.String()))
-		}
-
+		exitOnError(newScript(f))
 	} else if cmd == "create" {
-		if safeAddr == "" || targetAddr == "" {
-			log.Println("missing one of the required create parameter: safe-addr, target-addr")
+		if safeAddr == "" {
+			log.Println("missing one of the required create parameter: safe-addr")
 			flag.PrintDefaults()
 			os.Exit(1)
 		}
-
-		if rpcUrl == "" {
-			rpcUrl = "https://eth.llamarpc.com"
-		}
-		if chainId == "" {
-			chainId = "1"
-		}
-
-		env := []string{
-			"SAFE_ADDR=" + safeAddr,
-			"SAFE_NONCE=" + safeNonce,
-			"TARGET_ADDR=" + targetAddr,
-		}
-
-		outBuffer, _, err := shell.Run(workdir, "forge", env, "", false,
-			"script",
-			scriptName,
-			"--sig", "sign()",
-			"--rpc-url", rpcUrl,
-			"--chain-id", chainId,
-			"--via-ir")
-		if err != nil {
-			log.Printf("error running forge: %v\n", err)
-			os.Exit(1)
-		}
-		if safeNonce == "" {
-			safeNonce, err = extractNonce(outBuffer)
-			if err != nil {
-				log.Printf("error extracting nonce: %v\n", err)
-				os.Exit(1)
-			}
-		}
-		tx := &TxState{
-			ChainId:    chainId,
-			RpcUrl:     rpcUrl,
-			CreatedAt:  time.Now().Format(time.RFC3339),
-			SafeAddr:   safeAddr,
-			SafeNonce:  safeNonce,
-			TargetAddr: targetAddr,
-			ScriptName: scriptName,
-			Signatures: nil,
-		}
-
-		if jsonFile == "" {
-			jsonFile = fmt.Sprintf("tx/draft-%s.json", safeNonce)
+		if len(callSpecs) > 0 || callsFile != "" {
+			flag.Visit(func(f *flag.Flag) {
+				if f.Name == "script-name" && scriptName != "CallGeneric" {
+					log.Printf("--call and --calls-file can only be used with CallGeneric, not %s\n", scriptName)
+					os.Exit(1)
+				}
+			})
 		}
-		writeTxState(jsonFile, tx)
+		exitOnError(createTx(r, f))
 	} else if cmd == "sign" {
 		options := 0
 		if privateKey != "" {
@@ -261,194 +276,39 @@ func main() {
 			log.Printf("one (and only one) of --private-key, --ledger, --mnemonic must be set")
 			os.Exit(1)
 		}
-
-		tx := readTxState(jsonFile)
-
-		var signingFlags []string
-		if ledger {
-			signingFlags = append(signingFlags, "--ledger")
-			signingFlags = append(signingFlags, "--hd-paths", hdPath)
-		}
-		if mnemonic != "" {
-			signingFlags = append(signingFlags, "--mnemonic", mnemonic)
-		}
+		exitOnError(signTx(r, f))
+	} else if cmd == "approve" {
+		options := 0
 		if privateKey != "" {
-			signingFlags = append(signingFlags, "--private-key", privateKey)
-		}
-		signingFlags = append(signingFlags, "--workdir", workdir)
-
-		signingFlagsAddress := append(signingFlags, "--address")
-
-		// read wallet address from ledger
-		outBuffer, _, err := shell.Run(workdir, "eip712sign", []string{}, "", false, signingFlagsAddress...)
-		if err != nil {
-			log.Printf("error running eip712sign: %v\n", err)
-			os.Exit(1)
-		}
-		signer := senderAddr
-		if signer == "" {
-			var err error
-			signer, err = extractSigner(outBuffer)
-			if err != nil {
-				log.Printf("error running eip712sign: %v\n", err)
-				os.Exit(1)
-			}
-		}
-
-		log.Println("running simulation")
-
-		useRpcUrl := tx.RpcUrl
-		if rpcUrl != "" {
-			useRpcUrl = rpcUrl
-		}
-
-		env := []string{
-			"SAFE_ADDR=" + tx.SafeAddr,
-			"SAFE_NONCE=" + tx.SafeNonce,
-			"TARGET_ADDR=" + tx.TargetAddr,
+			options++
 		}
-
-		outBuffer, _, err = shell.Run(workdir, "forge", env, "", false,
-			"script",
-			tx.ScriptName,
-			"--sig", "sign()",
-			"--rpc-url", useRpcUrl,
-			"--chain-id", tx.ChainId,
-			"--sender", signer,
-			"--via-ir")
-
-		tx.Data = extractData(outBuffer)
-
-		// sign the payload
-		outBuffer, _, err = shell.Run(workdir, "eip712sign", []string{}, tx.Data+"\n", false, signingFlags...)
-		if err != nil {
-			log.Printf("error running eip712sign: %v\n", err)
-			os.Exit(1)
+		if ledger {
+			options++
 		}
-
-		_, sig, err := extractSignatures(outBuffer)
-		if err != nil {
-			log.Printf("error extracting signatures: %v\n", err)
+		if options != 1 {
+			log.Printf("one (and only one) of --private-key, --ledger must be set for approval")
 			os.Exit(1)
 		}
-
-		var found bool
-		for _, s := range tx.Signatures {
-			if s.Signer == signer {
-				log.Printf("signature for %s already exists, overwriting\n", signer)
-				s.Signature = sig
-				found = true
-				break
-			}
-		}
-		if !found {
-			tx.Signatures = append(tx.Signatures, TxSignature{
-				Signer:    signer,
-				Signature: sig,
-			})
-			log.Printf("added signature for %s\n", signer)
-		}
-		if jsonFile == "" || (strings.HasPrefix(path.Base(jsonFile), "draft-") && strings.HasSuffix(jsonFile, ".json")) {
-			newName, err := extractFilename(jsonFile, "draft", signer)
-			if err != nil {
-				log.Printf("error generating filename: %v\n", err)
-				os.Exit(1)
-			}
-			jsonFile = newName
-		}
-		writeTxState(jsonFile, tx)
-	} else if cmd == "verify" {
-		tx := readTxState(jsonFile)
-		if len(tx.Signatures) == 0 {
-			log.Printf("no signatures found\n")
+		exitOnError(approveTx(r, f))
+	} else if cmd == "add-signature" {
+		if !common.IsHexAddress(signerAddr) || signatureHex == "" {
+			log.Println("missing one of the required add-signature parameters: signer, signature")
+			flag.PrintDefaults()
 			os.Exit(1)
 		}
-		signatures := ""
-		for _, s := range tx.Signatures {
-			signatures = signatures + s.Signature
-		}
-		env := []string{
-			"SAFE_ADDR=" + tx.SafeAddr,
-			"SAFE_NONCE=" + tx.SafeNonce,
-			"TARGET_ADDR=" + tx.TargetAddr,
-		}
-		useRpcUrl := tx.RpcUrl
-		if rpcUrl != "" {
-			useRpcUrl = rpcUrl
-		}
-		outBuffer, _, err := shell.Run(workdir, "forge", env, "", false,
-			"script",
-			tx.ScriptName,
-			"--sig", "verify(bytes)", signatures,
-			"--rpc-url", useRpcUrl,
-			"--chain", tx.ChainId,
-			"--via-ir")
-		if err != nil {
-			log.Printf("error running forge: %v\n", err)
+		if contractSignature && ethSign {
+			log.Println("only one of --contract-signature, --eth-sign can be set")
 			os.Exit(1)
 		}
-
-		if strings.Contains(string(outBuffer), "Script ran successfully.") {
-			log.Printf("signatures are valid and tx is ready to be executed\n")
-		} else {
-			os.Exit(255) // succeeded but signatures are invalid
-		}
+		exitOnError(addSignature(r, f))
+	} else if cmd == "decode" {
+		exitOnError(decodeTx(f))
+	} else if cmd == "verify" {
+		exitOnError(verifyTx(r, f))
 	} else if cmd == "merge" {
-		tx := readTxState(jsonFile)
-
-		signatures := make(map[string]string, len(tx.Signatures))
-		for _, s := range tx.Signatures {
-			signatures[s.Signer] = s.Signature
-		}
-
-		for _, otherFile := range args[1:] {
-			otherTx := readTxState(otherFile)
-			if otherTx.SafeAddr != tx.SafeAddr {
-				log.Printf("safe addr mismatch for file: %s\n", otherFile)
-				log.Printf("   %s != %s\n", otherTx.SafeAddr, tx.SafeAddr)
-				os.Exit(1)
-			}
-			if otherTx.TargetAddr != tx.TargetAddr {
-				log.Printf("target addr mismatch for file: %s\n", otherFile)
-				log.Printf("   %s != %s\n", otherTx.TargetAddr, tx.TargetAddr)
-				os.Exit(1)
-			}
-			if otherTx.Data != tx.Data {
-				if tx.Data == "" {
-					tx.Data = otherTx.Data
-				} else {
-					log.Printf("data mismatch for file: %s\n", otherFile)
-					log.Printf("   %s != %s\n", otherTx.Data, tx.Data)
-					os.Exit(1)
-				}
-			}
-			if otherTx.SafeNonce != tx.SafeNonce {
-				log.Printf("nonce mismatch for file: %s\n", otherFile)
-				log.Printf("   %s != %s\n", otherTx.SafeNonce, tx.SafeNonce)
-				os.Exit(1)
-			}
-
-			for _, s := range otherTx.Signatures {
-				signatures[s.Signer] = s.Signature
-			}
-		}
-
-		newSigs := make([]TxSignature, 0, len(signatures))
-		for signer, sig := range signatures {
-			newSigs = append(newSigs, TxSignature{signer, sig})
-		}
-		tx.Signatures = newSigs
-
-		writeTxState(jsonFile, tx)
+		exitOnError(mergeTx(f, args[1:]))
 	} else if cmd == "execute" || cmd == "simulate" {
-		tx := readTxState(jsonFile)
-
 		if cmd == "execute" {
-			if len(tx.Signatures) == 0 {
-				log.Printf("no signatures found\n")
-				os.Exit(1)
-			}
-
 			options := 0
 			if privateKey != "" {
 				options++
@@ -461,119 +321,1708 @@ func main() {
 				os.Exit(1)
 			}
 		}
+		exitOnError(simulateTx(r, f, cmd == "execute"))
+	} else {
+		log.Println("unknown command, use one of: create, new-script, nonce, threshold, owners, pause-status, sign, approve, add-signature, merge, decode, verify, simulate, execute")
+		flag.PrintDefaults()
+		os.Exit(1)
+	}
+}
 
-		signatures := ""
-		for _, s := range tx.Signatures {
-			signatures = signatures + s.Signature
-		}
-		env := []string{
-			"SAFE_ADDR=" + tx.SafeAddr,
-			"SAFE_NONCE=" + tx.SafeNonce,
-			"TARGET_ADDR=" + tx.TargetAddr,
-		}
-		useRpcUrl := tx.RpcUrl
-		if rpcUrl != "" {
-			useRpcUrl = rpcUrl
-		}
-		var optFlags []string
-		var signingFlags []string
+// cmdFlags are the command line flags used by the commands.
+type cmdFlags struct {
+	workdir    string
+	jsonFile   string
+	scriptName string
+
+	// failures are printed as JSON to jsonOut if it is set
+	jsonOut io.Writer
+
+	// encrypts the transaction files written, if configured,
+	// files read are decrypted whether it is configured or not
+	envelope *envelope.Envelope
+
+	chainId         string
+	rpcUrl          string
+	safeAddr        string
+	safeNonce       string
+	targetAddr      string
+	targetsFile     string
+	callSpecs       []string
+	callsFile       string
+	pauseIdentifier string
+	paramSpecs      []string
+
+	delegatecallAllowlist string
+
+	fromBlock string
+
+	newScriptName string
+	abiFile       string
+	functionName  string
+
+	privateKey string
+	ledger     bool
+	mnemonic   string
+	hdPath     string
+	senderAddr string
+
+	signerAddr        string
+	signatureHex      string
+	contractSignature bool
+	ethSign           bool
+
+	useAnvil  bool
+	anvilPort string
+}
 
-		if ledger {
-			signingFlags = append(signingFlags, "--ledger")
-		}
-		if privateKey != "" {
-			signingFlags = append(signingFlags, "--private-key", privateKey)
-		}
-		if cmd == "execute" {
-			optFlags = append(optFlags,
-				"--broadcast",
-				"--sig", "run(bytes)", signatures)
-		} else if cmd == "simulate" {
-			optFlags = append(optFlags,
-				"--sig", "simulateSigned(bytes)", signatures)
-		}
+// invalidError is returned by the commands when the transaction or its signatures are invalid,
+// the presigner then exits with 255 instead of 1.
+type invalidError struct {
+	error
+}
 
-		execFlags := []string{
-			"script",
-			tx.ScriptName,
+func invalid(format string, a ...any) error {
+	return &invalidError{fmt.Errorf(format, a...)}
+}
 
-			"--rpc-url", useRpcUrl,
-			"--chain", tx.ChainId,
-			"--via-ir"}
-		execFlags = append(execFlags, optFlags...)
+// exitOnError logs err and exits, with 255 if the transaction is invalid.
+func exitOnError(err error) {
+	if err == nil {
+		return
+	}
+	log.Printf("%v\n", err)
+	var invalidErr *invalidError
+	if errors.As(err, &invalidErr) {
+		os.Exit(255)
+	}
+	os.Exit(1)
+}
+
+// printSafeState prints the nonce, threshold or owners of the safe, cmd is the command name.
+func printSafeState(r shell.Runner, f *cmdFlags, cmd string) error {
+	rpcUrl := f.rpcUrl
+	if rpcUrl == "" {
+		rpcUrl = "https://eth.llamarpc.com"
+	}
+	sig := map[string]string{
+		"nonce":     "nonce()",
+		"threshold": "getThreshold()",
+		"owners":    "getOwners()",
+	}[cmd]
+
+	env := []string{
+		"SAFE_ADDR=" + f.safeAddr,
+	}
+	outBuffer, _, err := r.Run("cast", env, "", true,
+		"call",
+		f.safeAddr,
+		sig,
+		"--rpc-url", rpcUrl)
+	if err != nil {
+		return fmt.Errorf("error running cast: %w", err)
+	}
 
-		outBuffer, _, err := shell.Run(workdir, "forge", env, "", false, execFlags...)
+	if cmd == "owners" {
+		owners, err := parseOwners(string(outBuffer))
 		if err != nil {
-			log.Printf("error running forge: %v\n", err)
-			os.Exit(1)
+			return fmt.Errorf("error reading owners: %w", err)
 		}
-
-		if strings.Contains(string(outBuffer), "Script ran successfully.") {
-			log.Printf("simulation succeeded\n")
-		} else {
-			os.Exit(255) // simulation failed
+		for _, owner := range owners {
+			fmt.Println(owner)
 		}
+		return nil
+	}
 
-		if cmd == "simulate" {
-			if jsonFile == "" || (strings.HasPrefix(path.Base(jsonFile), "draft-") && strings.HasSuffix(jsonFile, ".json")) {
-				newName, err := extractFilename(jsonFile, "ready", "")
-				if err != nil {
-					log.Printf("error generating filename: %v\n", err)
-					os.Exit(1)
-				}
-				jsonFile = newName
-			}
+	outBuffer, _, err = r.Run("cast", env, string(outBuffer), true,
+		"--to-dec")
+	if err != nil {
+		return fmt.Errorf("error running cast: %w", err)
+	}
+	fmt.Println(strings.TrimSpace(string(outBuffer)))
+	return nil
+}
 
-			calldata, err := extractCalldata(outBuffer)
-			if err != nil {
-				log.Printf("error extracting calldata: %v\n", err)
-				os.Exit(1)
+// pauseStatus prints the paused state, guardian and last pause event of a SuperchainConfig,
+// the transaction is invalid if --safe-addr is not its guardian.
+func pauseStatus(r shell.Runner, f *cmdFlags) error {
+	rpcUrl := f.rpcUrl
+	if rpcUrl == "" {
+		rpcUrl = "https://eth.llamarpc.com"
+	}
+
+	paused, err := cast.Call(r, rpcUrl, f.targetAddr, "paused()(bool)")
+	if err != nil {
+		return fmt.Errorf("error running cast: %w", err)
+	}
+	guardian, err := cast.Call(r, rpcUrl, f.targetAddr, "guardian()(address)")
+	if err != nil {
+		return fmt.Errorf("error running cast: %w", err)
+	}
+	fmt.Printf("paused: %s\n", paused)
+	fmt.Printf("guardian: %s\n", strings.ToLower(guardian))
+
+	// the guardian check below is still reported if the node cannot serve the logs
+	var last *cast.Log
+	var logsErr error
+	for _, event := range []string{"Paused(string)", "Unpaused()"} {
+		logs, err := cast.Logs(r, rpcUrl, f.targetAddr, f.fromBlock, crypto.Keccak256Hash([]byte(event)).Hex())
+		if err != nil {
+			logsErr = err
+			break
+		}
+		for i := range logs {
+			if len(logs[i].Topics) > 0 && (last == nil || last.Before(&logs[i])) {
+				last = &logs[i]
 			}
-			tx.Calldata = calldata
-			log.Printf("added calldata\n")
-			writeTxState(jsonFile, tx)
+		}
+	}
+	if logsErr != nil {
+		log.Printf("warning: could not read Paused/Unpaused events, try a later --from-block: %v\n", logsErr)
+	} else if last == nil {
+		fmt.Printf("last event: none since block %s\n", f.fromBlock)
+	} else if knownEvents[common.HexToHash(last.Topics[0])] == "Paused(string)" {
+		identifier, err := cast.DecodeString(last.Data)
+		if err != nil {
+			identifier = last.Data
+		}
+		fmt.Printf("last event: Paused(%q) in block %s tx %s\n", identifier, last.BlockNumber, last.TransactionHash)
+	} else {
+		fmt.Printf("last event: Unpaused() in block %s tx %s\n", last.BlockNumber, last.TransactionHash)
+	}
 
-			printExecuteInstructions(jsonFile, tx, useRpcUrl)
+	if f.safeAddr != "" {
+		if !strings.EqualFold(guardian, f.safeAddr) {
+			return invalid("%s", shell.Highlight(fmt.Sprintf("safe %s is NOT the guardian of %s and cannot pause it", f.safeAddr, f.targetAddr)))
+		}
+		log.Printf("safe %s is the guardian of %s\n", f.safeAddr, f.targetAddr)
+	}
+	return nil
+}
 
-			onelinerName := strings.ReplaceAll(jsonFile, ".json", ".sh.b64")
-			createOneLiner(onelinerName, tx)
+// newScript generates a script calling a function of a contract and registers it.
+func newScript(f *cmdFlags) error {
+	reg, err := registry.Load(f.workdir)
+	if err != nil {
+		return fmt.Errorf("error reading script registry: %w", err)
+	}
+	if _, ok := reg.Find(f.newScriptName); ok {
+		return fmt.Errorf("script %s is already registered", f.newScriptName)
+	}
 
-			oneliner := fmt.Sprintf("/bin/bash <(base64 -d -i %s) --rpc-url %s", onelinerName, useRpcUrl)
+	source, params, err := scaffold.Generate(f.newScriptName, f.abiFile, f.functionName)
+	if err != nil {
+		return fmt.Errorf("error generating script: %w", err)
+	}
 
-			log.Printf(`
+	scriptFile := f.newScriptName + ".s.sol"
+	if shell.ExistFile(path.Join(f.workdir, "script", scriptFile)) {
+		return fmt.Errorf("file script/%s already exists, exiting", scriptFile)
+	}
+	shell.WriteFile(path.Join(f.workdir, "script", scriptFile), []byte(source))
 
-to run oneliner:
-    %s
+	script := registry.Script{
+		Name:           f.newScriptName,
+		File:           scriptFile,
+		RequiresTarget: true,
+		Operation:      safe.OperationDelegateCall,
+	}
+	for _, p := range params {
+		script.Params = append(script.Params, registry.Param{
+			Name: p.Env,
+			Type: p.AbiType,
+		})
+	}
+	reg.Add(script)
+	if err := reg.Save(f.workdir); err != nil {
+		return fmt.Errorf("error saving script registry: %w", err)
+	}
 
-`, shell.Highlight(oneliner))
+	if len(params) > 0 {
+		log.Printf("the script reads the arguments of %s from the parameters, pass them to create with --param:\n", f.functionName)
+		for _, p := range params {
+			log.Printf("    %s (%s)\n", p.Env, p.AbiType)
 		}
-	} else {
-		log.Println("unknown command, use one of: create, nonce, threshold, owners, sign, merge, verify, simulate, execute")
-		flag.PrintDefaults()
-		os.Exit(1)
 	}
+	return nil
 }
 
-func printExecuteInstructions(jsonFile string, tx *TxState, useRpcUrl string) {
-	presignerCmd := fmt.Sprintf(`go run presigner.go \
-    --json-file %s \
-    --private-key $EXECUTORKEY \
-    execute`, jsonFile)
-	castCmd := fmt.Sprintf(
-		`SAFE_ADDR=%s
-CALLDATA=%s
-EXECUTORKEY=********
-cast send \
-    --rpc-url %s \
-    --chain %s \
-    --private-key $EXECUTORKEY \
-    $SAFE_ADDR \
-    $CALLDATA`,
-		tx.SafeAddr, tx.Calldata, useRpcUrl, tx.ChainId)
-
-	log.Printf(`
+// approveTx approves the transaction hash on-chain with approveHash, sent by the owner,
+// and adds its pre-validated signature.
+func approveTx(r shell.Runner, f *cmdFlags) error {
+	tx, err := readTxState(f.envelope, f.jsonFile)
+	if err != nil {
+		return err
+	}
 
-transaction now can be sent to network with:
+	printTxSummary(tx)
+	warnOperation(tx)
+	if err := checkOperation(tx, f.delegatecallAllowlist); err != nil {
+		return fmt.Errorf("refusing to approve: %w", err)
+	}
+
+	hash, err := safeTxHash(tx)
+	if err != nil {
+		return fmt.Errorf("%w, run create or sign first", err)
+	}
+
+	useRpcUrl := tx.RpcUrl
+	if f.rpcUrl != "" {
+		useRpcUrl = f.rpcUrl
+	}
+
+	var signingFlags []string
+	if f.ledger {
+		signingFlags = append(signingFlags, "--ledger")
+		signingFlags = append(signingFlags, "--mnemonic-derivation-path", f.hdPath)
+	}
+	if f.privateKey != "" {
+		signingFlags = append(signingFlags, "--private-key", f.privateKey)
+	}
+
+	log.Printf("approving %s on safe %s\n", hash, tx.SafeAddr)
+	receipt, err := cast.Send(r, useRpcUrl, signingFlags, tx.SafeAddr, "approveHash(bytes32)", hash.Hex())
+	if err != nil {
+		return fmt.Errorf("error running cast: %w", err)
+	}
+	if receipt.Status != "0x1" && receipt.Status != "1" {
+		return invalid("approveHash transaction %s reverted, is the sender an owner of the safe?", receipt.TransactionHash)
+	}
+
+	owner := strings.ToLower(receipt.From)
+	approved, err := isApproved(r, useRpcUrl, tx.SafeAddr, owner, hash)
+	if err != nil {
+		return fmt.Errorf("error running cast: %w", err)
+	}
+	if !approved {
+		return invalid("hash is not approved by %s after transaction %s", owner, receipt.TransactionHash)
+	}
+	log.Printf("hash approved by %s in transaction %s\n", owner, receipt.TransactionHash)
+
+	setSignature(tx, txstate.TxSignature{
+		Signer:    owner,
+		Signature: preValidatedSignature(owner),
+		Type:      txstate.SignatureApprovedHash,
+	})
+	return writeTxState(f.envelope, f.jsonFile, tx)
+}
+
+// addSignature adds a signature produced outside of the presigner, once verified.
+func addSignature(r shell.Runner, f *cmdFlags) error {
+	tx, err := readTxState(f.envelope, f.jsonFile)
+	if err != nil {
+		return err
+	}
+	useRpcUrl := tx.RpcUrl
+	if f.rpcUrl != "" {
+		useRpcUrl = f.rpcUrl
+	}
+
+	sig := txstate.TxSignature{
+		Signer:    strings.ToLower(f.signerAddr),
+		Signature: strings.TrimPrefix(f.signatureHex, "0x"),
+	}
+	if f.contractSignature {
+		sig.Type = txstate.SignatureContract
+	}
+	if f.ethSign {
+		signature, err := ethSignSignature(sig.Signature)
+		if err != nil {
+			return fmt.Errorf("invalid signature for %s: %w", sig.Signer, err)
+		}
+		sig.Signature = signature
+		sig.Type = txstate.SignatureEthSign
+	}
+	if err := verifySignature(r, useRpcUrl, tx, sig); err != nil {
+		return invalid("invalid signature for %s: %w", sig.Signer, err)
+	}
+	setSignature(tx, sig)
+	return writeTxState(f.envelope, f.jsonFile, tx)
+}
+
+// decodeTx prints the summary of the transaction, which is invalid if it delegatecalls
+// an address that is not allowed.
+func decodeTx(f *cmdFlags) error {
+	tx, err := readTxState(f.envelope, f.jsonFile)
+	if err != nil {
+		return err
+	}
+	printTxSummary(tx)
+	warnOperation(tx)
+	if err := checkOperation(tx, f.delegatecallAllowlist); err != nil {
+		return &invalidError{err}
+	}
+	return nil
+}
+
+// findScript returns the script registered as name, forge projects without a registry
+// fall back to an unregistered script whose name and parameters are not validated.
+func findScript(workdir, name string) (*registry.Script, bool, error) {
+	reg, err := registry.Load(workdir)
+	if errors.Is(err, fs.ErrNotExist) {
+		log.Printf("warning: %s not found in %s, %s and its parameters are not validated\n", registry.File, workdir, name)
+		return &registry.Script{Name: name, RequiresTarget: name != "CallGeneric"}, false, nil
+	}
+	if err != nil {
+		return nil, false, fmt.Errorf("error reading script registry: %w", err)
+	}
+	if err := reg.Validate(name); err != nil {
+		return nil, false, err
+	}
+	script, _ := reg.Find(name)
+	return script, true, nil
+}
+
+// createTx runs the sign() function of the script to create the transaction file,
+// and the approvals of the owners that are nested safes.
+func createTx(r shell.Runner, f *cmdFlags) error {
+	generic := len(f.callSpecs) > 0 || f.callsFile != ""
+	scriptName := f.scriptName
+	if generic {
+		scriptName = "CallGeneric"
+	} else if scriptName == "CallGeneric" {
+		return fmt.Errorf("missing one of the required create parameter for CallGeneric: call, calls-file")
+	}
+
+	script, registered, err := findScript(f.workdir, scriptName)
+	if err != nil {
+		return err
+	}
+	if script.Operation != "" && script.Operation != safe.OperationDelegateCall {
+		// the scripts are MultisigBuilders, signing them as a call would not match the safe transaction
+		return fmt.Errorf("unsupported operation %s for %s, MultisigBuilder scripts delegatecall Multicall3", script.Operation, scriptName)
+	}
+
+	if script.RequiresTarget && f.targetAddr == "" && f.targetsFile == "" {
+		return fmt.Errorf("missing one of the required create parameter for %s: target-addr, targets-file", scriptName)
+	}
+	if !script.RequiresTarget && (f.targetAddr != "" || f.targetsFile != "") {
+		return fmt.Errorf("%s does not use target-addr or targets-file", scriptName)
+	}
+
+	given := make(map[string]string, len(f.paramSpecs))
+	for _, spec := range f.paramSpecs {
+		name, value, ok := strings.Cut(spec, "=")
+		if !ok {
+			return fmt.Errorf("invalid parameter %q, expected NAME=VALUE", spec)
+		}
+		given[name] = value
+	}
+	if f.pauseIdentifier != "" {
+		given["PAUSE_IDENTIFIER"] = f.pauseIdentifier
+	}
+	params := given
+	if registered {
+		params, err = script.ResolveParams(given)
+		if err != nil {
+			return err
+		}
+	}
+
+	var calls []multicall.Call
+	var targets []string
+	if generic {
+		calls, err = parseCalls(f.callSpecs, f.callsFile)
+		if err != nil {
+			return fmt.Errorf("error parsing calls: %w", err)
+		}
+		if err := multicall.Encode(calls); err != nil {
+			return fmt.Errorf("error encoding calls: %w", err)
+		}
+		for _, call := range calls {
+			if !containsAddress(targets, call.Target) {
+				targets = append(targets, call.Target)
+			}
+		}
+	} else if script.RequiresTarget {
+		targets, err = parseTargets(f.targetAddr, f.targetsFile)
+		if err != nil {
+			return fmt.Errorf("error parsing targets: %w", err)
+		}
+	}
+
+	rpcUrl := f.rpcUrl
+	if rpcUrl == "" {
+		rpcUrl = "https://eth.llamarpc.com"
+	}
+	chainId := f.chainId
+	if chainId == "" {
+		chainId = "1"
+	}
+
+	tx := &txstate.TxState{
+		ChainId:    chainId,
+		RpcUrl:     rpcUrl,
+		CreatedAt:  time.Now().Format(time.RFC3339),
+		SafeAddr:   f.safeAddr,
+		SafeNonce:  f.safeNonce,
+		ScriptName: scriptName,
+		Params:     params,
+		Operation:  safe.OperationDelegateCall,
+		To:         safe.Multicall3Address,
+		Calls:      calls,
+		Signatures: nil,
+	}
+	if err := checkOperation(tx, f.delegatecallAllowlist); err != nil {
+		return err
+	}
+	if len(targets) > 0 {
+		tx.TargetAddr = targets[0]
+	}
+	if len(targets) > 1 {
+		tx.TargetAddrs = targets
+	}
+
+	env, err := scriptEnv(tx)
+	if err != nil {
+		return err
+	}
+	outBuffer, _, err := r.Run("forge", env, "", false,
+		"script",
+		scriptName,
+		"--sig", "sign()",
+		"--rpc-url", rpcUrl,
+		"--chain-id", chainId,
+		"--via-ir")
+	if err != nil {
+		return fmt.Errorf("error running forge: %w", err)
+	}
+	if tx.SafeNonce == "" {
+		tx.SafeNonce, err = extractNonce(outBuffer)
+		if err != nil {
+			return fmt.Errorf("error extracting nonce: %w", err)
+		}
+	}
+
+	if data := extractData(outBuffer); strings.HasPrefix(data, "0x1901") {
+		tx.Data = data
+	}
+
+	jsonFile := f.jsonFile
+	if jsonFile == "" {
+		jsonFile = fmt.Sprintf("tx/draft-%s.json", tx.SafeNonce)
+	}
+
+	if tx.Data == "" {
+		log.Printf("transaction data not found in forge output, not checking for nested safe owners\n")
+	} else if err := createApprovals(r, f.envelope, tx, jsonFile); err != nil {
+		return fmt.Errorf("error creating approvals for nested safe owners: %w", err)
+	}
+	return writeTxState(f.envelope, jsonFile, tx)
+}
+
+// signTx signs the transaction with eip712sign, after simulating it with forge to get the data to sign.
+func signTx(r shell.Runner, f *cmdFlags) error {
+	tx, err := readTxState(f.envelope, f.jsonFile)
+	if err != nil {
+		return err
+	}
+
+	warnOperation(tx)
+	if err := checkOperation(tx, f.delegatecallAllowlist); err != nil {
+		return fmt.Errorf("refusing to sign: %w", err)
+	}
+
+	var signingFlags []string
+	if f.ledger {
+		signingFlags = append(signingFlags, "--ledger")
+		signingFlags = append(signingFlags, "--hd-paths", f.hdPath)
+	}
+	if f.mnemonic != "" {
+		signingFlags = append(signingFlags, "--mnemonic", f.mnemonic)
+	}
+	if f.privateKey != "" {
+		signingFlags = append(signingFlags, "--private-key", f.privateKey)
+	}
+	signingFlags = append(signingFlags, "--workdir", f.workdir)
+
+	signingFlagsAddress := append(signingFlags, "--address")
+
+	// read wallet address from ledger
+	outBuffer, _, err := r.Run("eip712sign", []string{}, "", false, signingFlagsAddress...)
+	if err != nil {
+		return fmt.Errorf("error running eip712sign: %w", err)
+	}
+	signer := f.senderAddr
+	if signer == "" {
+		signer, err = extractSigner(outBuffer)
+		if err != nil {
+			return fmt.Errorf("error running eip712sign: %w", err)
+		}
+	}
+
+	log.Println("running simulation")
+
+	useRpcUrl := tx.RpcUrl
+	if f.rpcUrl != "" {
+		useRpcUrl = f.rpcUrl
+	}
+
+	env, err := scriptEnv(tx)
+	if err != nil {
+		return err
+	}
+
+	outBuffer, _, err = r.Run("forge", env, "", false,
+		"script",
+		tx.ScriptName,
+		"--sig", "sign()",
+		"--rpc-url", useRpcUrl,
+		"--chain-id", tx.ChainId,
+		"--sender", signer,
+		"--via-ir")
+	if err != nil {
+		return fmt.Errorf("error running forge: %w", err)
+	}
+
+	data := extractData(outBuffer)
+	if tx.Data != "" && data != tx.Data {
+		return invalid("refusing to sign: the simulation data differs from the transaction data\n   %s != %s", data, tx.Data)
+	}
+	tx.Data = data
+
+	// sign the payload
+	outBuffer, _, err = r.Run("eip712sign", []string{}, tx.Data+"\n", false, signingFlags...)
+	if err != nil {
+		return fmt.Errorf("error running eip712sign: %w", err)
+	}
+
+	_, sig, err := extractSignatures(outBuffer)
+	if err != nil {
+		return fmt.Errorf("error extracting signatures: %w", err)
+	}
+
+	setSignature(tx, txstate.TxSignature{
+		Signer:    signer,
+		Signature: sig,
+	})
+	jsonFile := f.jsonFile
+	if jsonFile == "" || (strings.HasPrefix(path.Base(jsonFile), "draft-") && strings.HasSuffix(jsonFile, ".json")) {
+		jsonFile, err = extractFilename(jsonFile, "draft", signer)
+		if err != nil {
+			return fmt.Errorf("error generating filename: %w", err)
+		}
+	}
+	return writeTxState(f.envelope, jsonFile, tx)
+}
+
+// verifyTx checks every signature, and that the safe accepts the assembled signatures.
+func verifyTx(r shell.Runner, f *cmdFlags) error {
+	tx, err := readTxState(f.envelope, f.jsonFile)
+	if err != nil {
+		return err
+	}
+	useRpcUrl := tx.RpcUrl
+	if f.rpcUrl != "" {
+		useRpcUrl = f.rpcUrl
+	}
+	if err := addApprovedHashes(r, useRpcUrl, tx); err != nil {
+		return fmt.Errorf("error reading approved hashes: %w", err)
+	}
+	if len(tx.Signatures) == 0 {
+		return errors.New("no signatures found")
+	}
+	signatures, q, err := assembleSignatures(r, useRpcUrl, tx)
+	if err != nil {
+		return fmt.Errorf("error assembling signatures: %w", err)
+	}
+	env, err := scriptEnv(tx)
+	if err != nil {
+		return err
+	}
+	printTxSummary(tx)
+	for _, sig := range tx.Signatures {
+		if err := verifySignature(r, useRpcUrl, tx, sig); err != nil {
+			log.Printf("invalid signature for %s: %v\n", sig.Signer, err)
+		}
+	}
+	warnOperation(tx)
+	if err := checkOperation(tx, f.delegatecallAllowlist); err != nil {
+		return &invalidError{err}
+	}
+	outBuffer, errBuffer, err := r.Run("forge", env, "", false,
+		"script",
+		tx.ScriptName,
+		"--sig", "verify(bytes)", signatures,
+		"--rpc-url", useRpcUrl,
+		"--chain", tx.ChainId,
+		"--via-ir")
+	if exitErr, failed := shell.IsExitError(err); failed {
+		if !reportFailure(q, f.jsonOut, outBuffer, errBuffer).Reverted() {
+			return exitErr
+		}
+		return invalid("signatures are invalid") // forge ran but signatures are invalid
+	}
+	if err != nil {
+		return fmt.Errorf("error running forge: %w", err)
+	}
+	log.Printf("signatures are valid and tx is ready to be executed\n")
+	return nil
+}
+
+// mergeTx adds the signatures of otherFiles to the transaction, they must be files of the same transaction.
+func mergeTx(f *cmdFlags, otherFiles []string) error {
+	tx, err := readTxState(f.envelope, f.jsonFile)
+	if err != nil {
+		return err
+	}
+
+	// keyed by the lowercase signer, the same owner may be checksummed in other files
+	signatures := make(map[string]txstate.TxSignature, len(tx.Signatures))
+	for _, s := range tx.Signatures {
+		s.Signer = strings.ToLower(s.Signer)
+		signatures[s.Signer] = s
+	}
+
+	for _, otherFile := range otherFiles {
+		otherTx, err := readTxState(f.envelope, otherFile)
+		if err != nil {
+			return err
+		}
+		if otherTx.SafeAddr != tx.SafeAddr {
+			return fmt.Errorf("safe addr mismatch for file: %s\n   %s != %s", otherFile, otherTx.SafeAddr, tx.SafeAddr)
+		}
+		if strings.Join(otherTx.Targets(), ",") != strings.Join(tx.Targets(), ",") {
+			return fmt.Errorf("target addr mismatch for file: %s\n   %s != %s", otherFile,
+				strings.Join(otherTx.Targets(), ","), strings.Join(tx.Targets(), ","))
+		}
+		if otherTx.Data != tx.Data {
+			if tx.Data == "" {
+				tx.Data = otherTx.Data
+			} else {
+				return fmt.Errorf("data mismatch for file: %s\n   %s != %s", otherFile, otherTx.Data, tx.Data)
+			}
+		}
+		if otherOperation, operation := fmt.Sprint(otherTx.SafeOperation()), fmt.Sprint(tx.SafeOperation()); otherOperation != operation {
+			return fmt.Errorf("operation mismatch for file: %s\n   %s != %s", otherFile, otherOperation, operation)
+		}
+		otherCalls, err := packCalls(otherTx)
+		if err != nil {
+			return fmt.Errorf("%w in file: %s", err, otherFile)
+		}
+		calls, err := packCalls(tx)
+		if err != nil {
+			return err
+		}
+		if otherCalls != calls {
+			return fmt.Errorf("calls mismatch for file: %s\n   %s != %s", otherFile, otherCalls, calls)
+		}
+		if otherParams, params := strings.Join(formatParams(otherTx), " "), strings.Join(formatParams(tx), " "); otherParams != params {
+			return fmt.Errorf("params mismatch for file: %s\n   %s != %s", otherFile, otherParams, params)
+		}
+		if otherTx.SafeNonce != tx.SafeNonce {
+			return fmt.Errorf("nonce mismatch for file: %s\n   %s != %s", otherFile, otherTx.SafeNonce, tx.SafeNonce)
+		}
+
+		for _, s := range otherTx.Signatures {
+			s.Signer = strings.ToLower(s.Signer)
+			signatures[s.Signer] = s
+		}
+	}
+
+	newSigs := make([]txstate.TxSignature, 0, len(signatures))
+	for _, sig := range signatures {
+		newSigs = append(newSigs, sig)
+	}
+	sort.Slice(newSigs, func(i, j int) bool {
+		return newSigs[i].Signer < newSigs[j].Signer
+	})
+	tx.Signatures = newSigs
+
+	return writeTxState(f.envelope, f.jsonFile, tx)
+}
+
+// simulateTx runs the transaction with the assembled signatures, and broadcasts it if execute is set.
+// A simulation adds the calldata to the transaction and creates its oneliner,
+// an execution checks that the transaction had the expected effect.
+func simulateTx(r shell.Runner, f *cmdFlags, execute bool) error {
+	cmd := "simulate"
+	if execute {
+		cmd = "execute"
+	}
+	tx, err := readTxState(f.envelope, f.jsonFile)
+	if err != nil {
+		return err
+	}
+	useRpcUrl := tx.RpcUrl
+	if f.rpcUrl != "" {
+		useRpcUrl = f.rpcUrl
+	}
+	if err := addApprovedHashes(r, useRpcUrl, tx); err != nil {
+		return fmt.Errorf("error reading approved hashes: %w", err)
+	}
+
+	if execute && len(tx.Signatures) == 0 {
+		return errors.New("no signatures found")
+	}
+
+	if err := checkOperation(tx, f.delegatecallAllowlist); err != nil {
+		return fmt.Errorf("refusing to %s: %w", cmd, err)
+	}
+
+	signatures, q, err := assembleSignatures(r, useRpcUrl, tx)
+	if err != nil {
+		return fmt.Errorf("error assembling signatures: %w", err)
+	}
+	env, err := scriptEnv(tx)
+	if err != nil {
+		return err
+	}
+	var optFlags []string
+	var signingFlags []string
+
+	if f.ledger {
+		signingFlags = append(signingFlags, "--ledger")
+	}
+	if f.privateKey != "" {
+		signingFlags = append(signingFlags, "--private-key", f.privateKey)
+	}
+	if execute {
+		optFlags = append(optFlags,
+			"--broadcast",
+			"--sig", "run(bytes)", signatures)
+	} else {
+		optFlags = append(optFlags,
+			"--sig", "simulateSigned(bytes)", signatures)
+	}
+
+	execFlags := []string{
+		"script",
+		tx.ScriptName,
+
+		"--rpc-url", useRpcUrl,
+		"--chain", tx.ChainId,
+		"--via-ir"}
+	execFlags = append(execFlags, optFlags...)
+
+	outBuffer, errBuffer, err := r.Run("forge", env, "", false, execFlags...)
+	if exitErr, failed := shell.IsExitError(err); failed {
+		if !reportFailure(q, f.jsonOut, outBuffer, errBuffer).Reverted() {
+			return exitErr
+		}
+		return invalid("simulation failed")
+	}
+	if err != nil {
+		return fmt.Errorf("error running forge: %w", err)
+	}
+	log.Printf("simulation succeeded\n")
+
+	if execute {
+		logs, err := readBroadcastLogs(f.workdir, tx)
+		if err != nil {
+			return invalid("error reading broadcast receipts: %w", err)
+		}
+		printEvents(logs)
+		if err := verifyEffect(r, f.workdir, useRpcUrl, tx, logs); err != nil {
+			return invalid("%s", shell.Highlight("EXECUTION DID NOT HAVE THE EXPECTED EFFECT: "+err.Error()))
+		}
+		log.Printf("execution verified\n")
+
+		tx.ExecutedAt = time.Now().Format(time.RFC3339)
+		return writeTxState(f.envelope, f.jsonFile, tx)
+	}
+
+	jsonFile := f.jsonFile
+	if jsonFile == "" || (strings.HasPrefix(path.Base(jsonFile), "draft-") && strings.HasSuffix(jsonFile, ".json")) {
+		jsonFile, err = extractFilename(jsonFile, "ready", "")
+		if err != nil {
+			return fmt.Errorf("error generating filename: %w", err)
+		}
+	}
+
+	calldata, err := extractCalldata(outBuffer)
+	if err != nil {
+		return fmt.Errorf("error extracting calldata: %w", err)
+	}
+	execTx, err := safe.DecodeExecTransaction(calldata)
+	if err != nil {
+		return fmt.Errorf("error decoding calldata: %w", err)
+	}
+	operation, to := tx.SafeOperation()
+	if execTx.OperationName() != operation || (to != "" && !strings.EqualFold(execTx.To.String(), to)) {
+		return invalid("calldata does not match the transaction: %s to %s, expected %s to %s",
+			execTx.OperationName(), execTx.To, operation, to)
+	}
+	tx.Operation = execTx.OperationName()
+	tx.To = execTx.To.String()
+	if err := checkOperation(tx, f.delegatecallAllowlist); err != nil {
+		return &invalidError{err}
+	}
+	tx.Calldata = calldata
+
+	// the ready files are only written once the transaction had the expected effect on the fork
+	if f.useAnvil {
+		if err := simulateOnFork(r, f.workdir, useRpcUrl, f.anvilPort, tx); err != nil {
+			return invalid("error simulating on anvil fork: %w", err)
+		}
+	}
+
+	log.Printf("added calldata\n")
+	if err := writeTxState(f.envelope, jsonFile, tx); err != nil {
+		return err
+	}
+
+	printExecuteInstructions(jsonFile, tx, useRpcUrl)
+
+	onelinerName := strings.ReplaceAll(jsonFile, ".json", oneliner.Ext)
+	if err := createOneLiner(f.envelope, onelinerName, tx); err != nil {
+		return err
+	}
+
+	onelinerCmd := fmt.Sprintf("/bin/bash <(base64 -d -i %s) --rpc-url %s", onelinerName, useRpcUrl)
+	if f.envelope.Enabled() {
+		decrypt := "age -d"
+		if f.envelope.Identity != "" {
+			decrypt += " -i " + f.envelope.Identity
+		} else if !f.envelope.Passphrase {
+			decrypt += " -i <identity>"
+		}
+		onelinerCmd = fmt.Sprintf("/bin/bash <(%s %s | base64 -d) --rpc-url %s", decrypt, onelinerName, useRpcUrl)
+	}
+
+	log.Printf(`
+
+to run oneliner:
+    %s
+
+`, shell.Highlight(onelinerCmd))
+	return nil
+}
+
+var knownEvents = map[common.Hash]string{
+	crypto.Keccak256Hash([]byte("Paused(string)")):                    "Paused(string)",
+	crypto.Keccak256Hash([]byte("Unpaused()")):                        "Unpaused()",
+	crypto.Keccak256Hash([]byte("ExecutionSuccess(bytes32,uint256)")): "ExecutionSuccess(bytes32,uint256)",
+	crypto.Keccak256Hash([]byte("ExecutionFailure(bytes32,uint256)")): "ExecutionFailure(bytes32,uint256)",
+}
+
+func simulateOnFork(r shell.Runner, workdir, rpcUrl, port string, tx *txstate.TxState) error {
+	node, err := anvil.Start(r, rpcUrl, port, tx.ChainId)
+	if err != nil {
+		return err
+	}
+	defer node.Stop()
+
+	pausedBefore := make(map[string]string)
+	for _, target := range tx.Targets() {
+		paused, err := cast.Call(r, node.URL(), target, "paused()(bool)")
+		if err != nil {
+			return fmt.Errorf("reading paused() of %s before execution: %w", target, err)
+		}
+		pausedBefore[target] = paused
+	}
+
+	log.Printf("executing signed transaction on anvil fork\n")
+	receipt, err := cast.SendUnlocked(r, node.URL(), anvil.DefaultSender, tx.SafeAddr, tx.Calldata)
+	if err != nil {
+		return fmt.Errorf("sending transaction: %w", err)
+	}
+	if receipt.Status != "0x1" && receipt.Status != "1" {
+		return fmt.Errorf("transaction %s reverted", receipt.TransactionHash)
+	}
+
+	printEvents(receipt.Logs)
+
+	diff, err := cast.TraceStateDiff(r, node.URL(), receipt.TransactionHash)
+	if err != nil {
+		log.Printf("state diff not available: %v\n", err)
+	} else {
+		printStateDiff(diff)
+	}
+
+	for _, target := range tx.Targets() {
+		log.Printf("paused() on %s before execution: %s\n", target, pausedBefore[target])
+	}
+	if err := verifyEffect(r, workdir, node.URL(), tx, receipt.Logs); err != nil {
+		return err
+	}
+	log.Printf("fork simulation succeeded\n")
+	return nil
+}
+
+// verifyEffect checks that the safe executed the inner calls and that every
+// target was left in the state expected by the script.
+func verifyEffect(r shell.Runner, workdir, rpcUrl string, tx *txstate.TxState, logs []cast.Log) error {
+	targetEvents := make(map[string][]string)
+	for _, l := range logs {
+		if len(l.Topics) == 0 {
+			continue
+		}
+		event := knownEvents[common.HexToHash(l.Topics[0])]
+		if event == "ExecutionFailure(bytes32,uint256)" && strings.EqualFold(l.Address, tx.SafeAddr) {
+			return fmt.Errorf("safe reported ExecutionFailure")
+		}
+		if !containsAddress(tx.Targets(), l.Address) || event == "" {
+			continue
+		}
+		if event == "Paused(string)" {
+			identifier, err := cast.DecodeString(l.Data)
+			if err != nil {
+				identifier = l.Data
+			}
+			log.Printf("%s emitted Paused(%q)\n", l.Address, identifier)
+			if expected, ok := tx.Params["PAUSE_IDENTIFIER"]; ok && identifier != expected {
+				return fmt.Errorf("expected pause identifier %q, got %q", expected, identifier)
+			}
+		}
+		target := strings.ToLower(l.Address)
+		targetEvents[target] = append(targetEvents[target], event)
+	}
+
+	reg, err := registry.Load(workdir)
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return fmt.Errorf("reading script registry: %w", err)
+	}
+	var script *registry.Script
+	ok := false
+	if reg != nil {
+		script, ok = reg.Find(tx.ScriptName)
+	}
+	if !ok || script.PostCondition == "" {
+		log.Printf("no post-condition known for %s, skipping effect verification\n", tx.ScriptName)
+		return nil
+	}
+	if script.PostCondition != registry.PostConditionPaused && script.PostCondition != registry.PostConditionUnpaused {
+		return fmt.Errorf("unknown post-condition %s for %s", script.PostCondition, tx.ScriptName)
+	}
+	expected := script.PostCondition == registry.PostConditionPaused
+
+	expectedEvent := "Unpaused()"
+	if expected {
+		expectedEvent = "Paused(string)"
+	}
+	for _, target := range tx.Targets() {
+		paused, err := cast.Call(r, rpcUrl, target, "paused()(bool)")
+		if err != nil {
+			return fmt.Errorf("reading paused() of %s: %w", target, err)
+		}
+		log.Printf("paused() on %s after execution: %s\n", target, paused)
+		if paused != strconv.FormatBool(expected) {
+			return fmt.Errorf("expected paused() of %s to be %t after execution, got %s", target, expected, paused)
+		}
+
+		var emitted bool
+		for _, event := range targetEvents[strings.ToLower(target)] {
+			if event == expectedEvent {
+				emitted = true
+			}
+		}
+		if !emitted {
+			return fmt.Errorf("expected %s to emit %s", target, expectedEvent)
+		}
+	}
+	return nil
+}
+
+func containsAddress(addrs []string, addr string) bool {
+	for _, a := range addrs {
+		if strings.EqualFold(a, addr) {
+			return true
+		}
+	}
+	return false
+}
+
+// readBroadcastLogs returns the logs of the receipts forge saved for the last broadcast of a script.
+func readBroadcastLogs(workdir string, tx *txstate.TxState) ([]cast.Log, error) {
+	script, _, err := findScript(workdir, tx.ScriptName)
+	if err != nil {
+		return nil, err
+	}
+	scriptFile := script.File
+	if scriptFile == "" {
+		scriptFile = tx.ScriptName + ".s.sol"
+	}
+	// forge names the broadcast directory after the file of the script
+	file := path.Join(workdir, "broadcast", path.Base(scriptFile), tx.ChainId, "run-latest.json")
+	jsonContents, err := os.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	var broadcast struct {
+		Receipts []cast.Receipt `json:"receipts"`
+	}
+	if err := json.Unmarshal(jsonContents, &broadcast); err != nil {
+		return nil, err
+	}
+	var logs []cast.Log
+	for _, receipt := range broadcast.Receipts {
+		if receipt.Status != "0x1" && receipt.Status != "1" {
+			return nil, fmt.Errorf("transaction %s reverted", receipt.TransactionHash)
+		}
+		logs = append(logs, receipt.Logs...)
+	}
+	return logs, nil
+}
+
+func printEvents(logs []cast.Log) {
+	log.Printf("emitted events:\n")
+	for _, l := range logs {
+		name := "unknown"
+		if len(l.Topics) > 0 {
+			if known, ok := knownEvents[common.HexToHash(l.Topics[0])]; ok {
+				name = known
+			}
+		}
+		log.Printf("  %s %s\n", l.Address, name)
+		for i, topic := range l.Topics {
+			log.Printf("    topic[%d]: %s\n", i, topic)
+		}
+		if l.Data != "" && l.Data != "0x" {
+			log.Printf("    data: %s\n", l.Data)
+		}
+	}
+}
+
+func printStateDiff(diff *cast.StateDiff) {
+	addrs := make([]string, 0, len(diff.Post))
+	for addr := range diff.Post {
+		addrs = append(addrs, addr)
+	}
+	sort.Strings(addrs)
+
+	log.Printf("storage diff:\n")
+	for _, addr := range addrs {
+		post := diff.Post[addr]
+		if len(post.Storage) == 0 {
+			continue
+		}
+		slots := make([]string, 0, len(post.Storage))
+		for slot := range post.Storage {
+			slots = append(slots, slot)
+		}
+		sort.Strings(slots)
+
+		log.Printf("  %s\n", addr)
+		for _, slot := range slots {
+			before := diff.Pre[addr].Storage[slot]
+			if before == "" {
+				before = "0x0"
+			}
+			log.Printf("    %s: %s -> %s\n", slot, before, post.Storage[slot])
+		}
+	}
+}
+
+// assembleSignatures concatenates the signatures of the quorum in the format expected by execTransaction.
+func assembleSignatures(r shell.Runner, rpcUrl string, tx *txstate.TxState) (string, *quorum, error) {
+	q, err := selectQuorum(r, rpcUrl, tx)
+	if err != nil {
+		return "", nil, err
+	}
+
+	// contract signatures point to their data, appended after the static part
+	signatures := ""
+	dynamic := ""
+	offset := 65 * len(q.selected)
+	for _, s := range q.selected {
+		if s.Type != txstate.SignatureContract {
+			signatures = signatures + s.Signature
+			continue
+		}
+		data := strings.TrimPrefix(s.Signature, "0x")
+		length := len(data) / 2
+		r := common.LeftPadBytes(common.HexToAddress(s.Signer).Bytes(), 32)
+		signatures = signatures + hex.EncodeToString(r) + fmt.Sprintf("%064x", offset) + "00"
+		dynamic = dynamic + fmt.Sprintf("%064x", length) + data
+		offset += 32 + length
+	}
+	return signatures + dynamic, q, nil
+}
+
+// quorum is the signatures passed to the safe, the ones left out as invalid,
+// and the owners and threshold of the safe they were selected with.
+type quorum struct {
+	selected  []txstate.TxSignature
+	dropped   []safe.DroppedSignature
+	owners    []string
+	threshold int
+}
+
+func (q *quorum) drop(s txstate.TxSignature, reason string) {
+	log.Printf("leaving out signature of %s: %s\n", s.Signer, reason)
+	q.dropped = append(q.dropped, safe.DroppedSignature{Signer: s.Signer, Reason: reason})
+}
+
+// explainContext returns the state to explain a failure of the safe with the signatures of the quorum.
+func (q *quorum) explainContext() safe.ExplainContext {
+	ctx := safe.ExplainContext{
+		Dropped:   q.dropped,
+		Owners:    q.owners,
+		Threshold: q.threshold,
+	}
+	for _, s := range q.selected {
+		ctx.Signers = append(ctx.Signers, s.Signer)
+	}
+	return ctx
+}
+
+// selectQuorum returns the valid signatures of current owners, sorted by signer as the safe
+// requires owners in ascending order, limited to the threshold of the safe.
+func selectQuorum(r shell.Runner, rpcUrl string, tx *txstate.TxState) (*quorum, error) {
+	owners, err := readOwners(r, rpcUrl, tx.SafeAddr)
+	if err != nil {
+		return nil, fmt.Errorf("reading owners: %w", err)
+	}
+	threshold, err := readThreshold(r, rpcUrl, tx.SafeAddr)
+	if err != nil {
+		return nil, fmt.Errorf("reading threshold: %w", err)
+	}
+
+	q := &quorum{owners: owners, threshold: threshold}
+	var signers []string
+	for _, s := range tx.Signatures {
+		if !containsAddress(owners, s.Signer) {
+			q.drop(s, fmt.Sprintf("not an owner of safe %s", tx.SafeAddr))
+			continue
+		}
+		// the safe requires strictly ascending owners, a second signature of an owner reverts
+		if containsAddress(signers, s.Signer) {
+			q.drop(s, "duplicate signature of the owner")
+			continue
+		}
+		if err := verifySignature(r, rpcUrl, tx, s); err != nil {
+			q.drop(s, err.Error())
+			continue
+		}
+		q.selected = append(q.selected, s)
+		signers = append(signers, s.Signer)
+	}
+	sort.SliceStable(q.selected, func(i, j int) bool {
+		return common.HexToAddress(q.selected[i].Signer).Big().Cmp(common.HexToAddress(q.selected[j].Signer).Big()) < 0
+	})
+
+	if len(q.selected) < threshold {
+		log.Printf("only %d signatures of owners, threshold is %d\n", len(q.selected), threshold)
+		return q, nil
+	}
+	for _, s := range q.selected[threshold:] {
+		log.Printf("leaving out signature of %s: threshold of %d already reached\n", s.Signer, threshold)
+	}
+	q.selected = q.selected[:threshold]
+	return q, nil
+}
+
+// ethSignSignature converts a personal_sign signature to the format accepted by the
+// safe: v is 27 or 28 (or 0 or 1 for some wallets) and is stored as v + 4.
+func ethSignSignature(signature string) (string, error) {
+	sig, err := hex.DecodeString(signature)
+	if err != nil {
+		return "", err
+	}
+	if len(sig) != 65 {
+		return "", fmt.Errorf("invalid signature length %d", len(sig))
+	}
+	switch sig[64] {
+	case 0, 1:
+		sig[64] += 31
+	case 27, 28:
+		sig[64] += 4
+	case 31, 32:
+	default:
+		return "", fmt.Errorf("invalid signature v %d", sig[64])
+	}
+	return hex.EncodeToString(sig), nil
+}
+
+// verifySignature checks a signature against the transaction hash without running forge.
+func verifySignature(r shell.Runner, rpcUrl string, tx *txstate.TxState, sig txstate.TxSignature) error {
+	data, err := hexutil.Decode(tx.Data)
+	if err != nil {
+		return fmt.Errorf("invalid transaction data: %w", err)
+	}
+	hash := crypto.Keccak256Hash(data)
+	signer := common.HexToAddress(sig.Signer)
+
+	switch sig.Type {
+	case txstate.SignatureApprovedHash:
+		approved, err := isApproved(r, rpcUrl, tx.SafeAddr, sig.Signer, hash)
+		if err != nil {
+			return err
+		}
+		if !approved {
+			return fmt.Errorf("hash %s is not approved yet", hash)
+		}
+		return nil
+	case txstate.SignatureEthSign:
+		signature, err := hex.DecodeString(strings.TrimPrefix(sig.Signature, "0x"))
+		if err != nil {
+			return fmt.Errorf("invalid signature: %w", err)
+		}
+		recovered, err := safe.RecoverEthSignSigner(hash, signature)
+		if err != nil {
+			return err
+		}
+		if recovered != signer {
+			return fmt.Errorf("signed by %s", strings.ToLower(recovered.Hex()))
+		}
+		return nil
+	case txstate.SignatureContract:
+		signature, err := hex.DecodeString(strings.TrimPrefix(sig.Signature, "0x"))
+		if err != nil {
+			return fmt.Errorf("invalid signature: %w", err)
+		}
+		return safe.VerifyContractSignature(r, rpcUrl, signer, data, signature)
+	default:
+		signature, err := hex.DecodeString(strings.TrimPrefix(sig.Signature, "0x"))
+		if err != nil {
+			return fmt.Errorf("invalid signature: %w", err)
+		}
+		recovered, err := safe.RecoverSigner(hash, signature)
+		if err != nil {
+			return err
+		}
+		if recovered != signer {
+			return fmt.Errorf("signed by %s", strings.ToLower(recovered.Hex()))
+		}
+		return nil
+	}
+}
+
+// setSignature adds the signature of its signer, replacing any previous one.
+// Signers are stored in lowercase, as the owners read from the safe.
+func setSignature(tx *txstate.TxState, sig txstate.TxSignature) {
+	sig.Signer = strings.ToLower(sig.Signer)
+	for i, s := range tx.Signatures {
+		if strings.EqualFold(s.Signer, sig.Signer) {
+			log.Printf("signature for %s already exists, overwriting\n", sig.Signer)
+			tx.Signatures[i] = sig
+			return
+		}
+	}
+	tx.Signatures = append(tx.Signatures, sig)
+	log.Printf("added signature for %s\n", sig.Signer)
+}
+
+// isApproved returns true if owner approved hash on-chain with approveHash.
+func isApproved(r shell.Runner, rpcUrl, safeAddr, owner string, hash common.Hash) (bool, error) {
+	out, err := cast.Call(r, rpcUrl, safeAddr, "approvedHashes(address,bytes32)(uint256)", owner, hash.Hex())
+	if err != nil {
+		return false, err
+	}
+	fields := strings.Fields(out)
+	if len(fields) == 0 {
+		return false, fmt.Errorf("result has invalid format")
+	}
+	return fields[0] != "0", nil
+}
+
+// addApprovedHashes adds a pre-validated signature for every owner without
+// a signature that approved the transaction hash on-chain.
+func addApprovedHashes(r shell.Runner, rpcUrl string, tx *txstate.TxState) error {
+	if tx.Data == "" {
+		log.Printf("transaction data not found, not checking for approved hashes\n")
+		return nil
+	}
+	hash, err := safeTxHash(tx)
+	if err != nil {
+		return err
+	}
+	owners, err := readOwners(r, rpcUrl, tx.SafeAddr)
+	if err != nil {
+		return fmt.Errorf("reading owners: %w", err)
+	}
+
+	for _, owner := range owners {
+		signed := false
+		for _, s := range tx.Signatures {
+			if strings.EqualFold(s.Signer, owner) {
+				signed = true
+				break
+			}
+		}
+		if signed {
+			continue
+		}
+		approved, err := isApproved(r, rpcUrl, tx.SafeAddr, owner, hash)
+		if err != nil {
+			return err
+		}
+		if approved {
+			log.Printf("%s approved the hash on-chain, adding pre-validated signature\n", owner)
+			tx.Signatures = append(tx.Signatures, txstate.TxSignature{
+				Signer:    owner,
+				Signature: preValidatedSignature(owner),
+				Type:      txstate.SignatureApprovedHash,
+			})
+		}
+	}
+	return nil
+}
+
+// preValidatedSignature encodes the signature of an owner that approved the hash
+// on-chain, or sends the transaction itself: r = owner, s = 0, v = 1.
+func preValidatedSignature(owner string) string {
+	r := common.LeftPadBytes(common.HexToAddress(owner).Bytes(), 32)
+	s := make([]byte, 32)
+	return hex.EncodeToString(r) + hex.EncodeToString(s) + "01"
+}
+
+// safeTxHash returns the hash signed by the owners, i.e. keccak256 of the EIP-712 data.
+func safeTxHash(tx *txstate.TxState) (common.Hash, error) {
+	data, err := hexutil.Decode(tx.Data)
+	if err != nil {
+		return common.Hash{}, fmt.Errorf("invalid transaction data: %w", err)
+	}
+	return crypto.Keccak256Hash(data), nil
+}
+
+// isSafe returns true if addr is a contract implementing getThreshold(),
+// errors other than a revert of the call, e.g. of the RPC, are returned.
+func isSafe(r shell.Runner, rpcUrl, addr string) (bool, error) {
+	code, err := cast.Code(r, rpcUrl, addr)
+	if err != nil {
+		return false, fmt.Errorf("reading code of %s: %w", addr, err)
+	}
+	if code == "0x" || code == "" {
+		return false, nil
+	}
+	// not decoded by cast, the call of a contract with a fallback may return nothing
+	out, err := cast.Call(r, rpcUrl, addr, "getThreshold()")
+	if cast.IsRevert(err) {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("reading threshold of %s: %w", addr, err)
+	}
+	threshold, err := hexutil.Decode(out)
+	return err == nil && len(threshold) == 32 && common.BytesToHash(threshold) != (common.Hash{}), nil
+}
+
+// createApprovals creates an ApproveHash transaction for every owner of the safe
+// that is itself a safe, addApprovedHashes adds its signature once approved on-chain.
+func createApprovals(r shell.Runner, fileEnvelope *envelope.Envelope, tx *txstate.TxState, jsonFile string) error {
+	owners, err := readOwners(r, tx.RpcUrl, tx.SafeAddr)
+	if err != nil {
+		return fmt.Errorf("reading owners: %w", err)
+	}
+	hash, err := safeTxHash(tx)
+	if err != nil {
+		return err
+	}
+
+	for _, owner := range owners {
+		nested, err := isSafe(r, tx.RpcUrl, owner)
+		if err != nil {
+			return err
+		}
+		if !nested {
+			continue
+		}
+		log.Printf("owner %s is a safe, creating its approveHash transaction for %s\n", owner, hash)
+
+		child := &txstate.TxState{
+			ChainId:    tx.ChainId,
+			RpcUrl:     tx.RpcUrl,
+			CreatedAt:  time.Now().Format(time.RFC3339),
+			SafeAddr:   owner,
+			TargetAddr: tx.SafeAddr,
+			ScriptName: "ApproveHash",
+			Params: map[string]string{
+				"APPROVE_HASH": hash.Hex(),
+			},
+			Operation: safe.OperationDelegateCall,
+			To:        safe.Multicall3Address,
+		}
+		env, err := scriptEnv(child)
+		if err != nil {
+			return err
+		}
+		outBuffer, _, err := r.Run("forge", env, "", false,
+			"script",
+			child.ScriptName,
+			"--sig", "sign()",
+			"--rpc-url", child.RpcUrl,
+			"--chain-id", child.ChainId,
+			"--via-ir")
+		if err != nil {
+			return fmt.Errorf("running forge: %w", err)
+		}
+		child.SafeNonce, err = extractNonce(outBuffer)
+		if err != nil {
+			return fmt.Errorf("extracting nonce: %w", err)
+		}
+		if data := extractData(outBuffer); strings.HasPrefix(data, "0x1901") {
+			child.Data = data
+		}
+
+		childFile := path.Join(path.Dir(jsonFile), fmt.Sprintf("draft-approve-%s-%s.json", owner, child.SafeNonce))
+		if err := writeTxState(fileEnvelope, childFile, child); err != nil {
+			return err
+		}
+		log.Printf("saved approval of %s to %s, its signature is added once executed\n", owner, childFile)
+	}
+	return nil
+}
+
+// defaultPauseIdentifier is the identifier CallPause uses when PAUSE_IDENTIFIER is not set,
+// passed for files created without the parameter so that they keep their data.
+const defaultPauseIdentifier = "presigner"
+
+// scriptEnv returns the environment the forge scripts read their parameters from.
+func scriptEnv(tx *txstate.TxState) ([]string, error) {
+	env := []string{
+		"SAFE_ADDR=" + tx.SafeAddr,
+		"SAFE_NONCE=" + tx.SafeNonce,
+		"TARGET_ADDR=" + tx.TargetAddr,
+	}
+	env = append(env, formatParams(tx)...)
+	// every variable read by the scripts is set, so none is taken from the shell of the operator
+	if _, ok := tx.Params["PAUSE_IDENTIFIER"]; !ok {
+		env = append(env, "PAUSE_IDENTIFIER="+defaultPauseIdentifier)
+	}
+	env = append(env, "TARGET_ADDRS="+strings.Join(tx.Targets(), ","))
+	packed, err := packCalls(tx)
+	if err != nil {
+		return nil, err
+	}
+	env = append(env, "CALLS="+packed)
+	return env, nil
+}
+
+// formatParams returns the script parameters as NAME=VALUE, sorted by name.
+func formatParams(tx *txstate.TxState) []string {
+	params := make([]string, 0, len(tx.Params))
+	for name, value := range tx.Params {
+		params = append(params, name+"="+value)
+	}
+	sort.Strings(params)
+	return params
+}
+
+// packCalls encodes the calls of a CallGeneric transaction, empty for other scripts.
+func packCalls(tx *txstate.TxState) (string, error) {
+	if len(tx.Calls) == 0 {
+		return "", nil
+	}
+	packed, err := multicall.Pack(tx.Calls)
+	if err != nil {
+		return "", fmt.Errorf("error encoding calls: %w", err)
+	}
+	return packed, nil
+}
+
+// parseCalls reads the calls from the --call flags and the --calls-file.
+func parseCalls(callSpecs []string, callsFile string) ([]multicall.Call, error) {
+	var calls []multicall.Call
+	for _, spec := range callSpecs {
+		call, err := multicall.ParseSpec(spec)
+		if err != nil {
+			return nil, err
+		}
+		calls = append(calls, call)
+	}
+	if callsFile != "" {
+		fileCalls, err := multicall.ReadFile(callsFile)
+		if err != nil {
+			return nil, err
+		}
+		calls = append(calls, fileCalls...)
+	}
+	if len(calls) == 0 {
+		return nil, fmt.Errorf("no calls")
+	}
+	return calls, nil
+}
+
+// parseTargets reads the target addresses from the --target-addr list and the --targets-file.
+func parseTargets(targetAddr, targetsFile string) ([]string, error) {
+	var entries []string
+	if targetAddr != "" {
+		entries = append(entries, strings.Split(targetAddr, ",")...)
+	}
+	if targetsFile != "" {
+		contents, err := os.ReadFile(targetsFile)
+		if err != nil {
+			return nil, err
+		}
+		for _, line := range strings.Split(string(contents), "\n") {
+			if strings.HasPrefix(strings.TrimSpace(line), "#") {
+				continue
+			}
+			entries = append(entries, line)
+		}
+	}
+
+	var targets []string
+	for _, entry := range entries {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		if !common.IsHexAddress(entry) {
+			return nil, fmt.Errorf("invalid target address: %s", entry)
+		}
+		if containsAddress(targets, entry) {
+			return nil, fmt.Errorf("duplicate target address: %s", entry)
+		}
+		targets = append(targets, entry)
+	}
+	if len(targets) == 0 {
+		return nil, fmt.Errorf("no target address")
+	}
+	return targets, nil
+}
+
+func printTxSummary(tx *txstate.TxState) {
+	fmt.Printf("chain id:         %s\n", tx.ChainId)
+	fmt.Printf("created at:       %s\n", tx.CreatedAt)
+	fmt.Printf("safe:             %s\n", tx.SafeAddr)
+	fmt.Printf("safe nonce:       %s\n", tx.SafeNonce)
+	fmt.Printf("script:           %s\n", tx.ScriptName)
+	operation, to := tx.SafeOperation()
+	fmt.Printf("operation:        %s %s\n", strings.ToUpper(operation), to)
+	for _, target := range tx.Targets() {
+		fmt.Printf("target:           %s\n", target)
+	}
+	for _, param := range formatParams(tx) {
+		fmt.Printf("param:            %s\n", param)
+	}
+	for _, call := range tx.Calls {
+		fmt.Printf("call:             %s %s %s\n", call.Target, call.Signature, strings.Join(call.Args, " "))
+		if call.AllowFailure {
+			fmt.Printf("    allow failure\n")
+		}
+		fmt.Printf("    %s\n", call.Calldata)
+	}
+	fmt.Printf("signatures:       %d\n", len(tx.Signatures))
+	for _, s := range tx.Signatures {
+		if s.Type != "" {
+			fmt.Printf("    %s (%s)\n", s.Signer, s.Type)
+		} else {
+			fmt.Printf("    %s\n", s.Signer)
+		}
+	}
+	if tx.Calldata != "" {
+		fmt.Printf("calldata:         %s\n", tx.Calldata)
+	}
+}
+
+// warnOperation flags delegatecalls, which run the code of another contract in the context of the safe.
+func warnOperation(tx *txstate.TxState) {
+	operation, to := tx.SafeOperation()
+	if operation != safe.OperationDelegateCall {
+		return
+	}
+	log.Printf("%s\n", shell.Highlight(fmt.Sprintf(
+		"!!! DELEGATECALL to %s: its code runs with the storage and balance of safe %s !!!", to, tx.SafeAddr)))
+}
+
+// checkOperation enforces that delegatecalls only go to allowlisted addresses.
+func checkOperation(tx *txstate.TxState, allowlist string) error {
+	operation, to := tx.SafeOperation()
+	switch operation {
+	case safe.OperationCall:
+		return nil
+	case safe.OperationDelegateCall:
+		for _, allowed := range strings.Split(allowlist, ",") {
+			if strings.EqualFold(strings.TrimSpace(allowed), to) {
+				return nil
+			}
+		}
+		return fmt.Errorf("delegatecall to %s is not allowed, see --delegatecall-allowlist", to)
+	}
+	return fmt.Errorf("unknown operation %s", operation)
+}
+
+// reportFailure decodes the safe error code from a failed forge run and explains it with the signatures of q.
+func reportFailure(q *quorum, jsonOut io.Writer, outBuffer, errBuffer []byte) *safe.Failure {
+	output := append(append([]byte{}, outBuffer...), errBuffer...)
+	failure := safe.DecodeFailure(output)
+	if failure.Code != "" {
+		failure.Explanation = safe.Explain(failure.Code, q.explainContext())
+	}
+
+	if jsonOut != nil {
+		jsonContents, err := json.Marshal(struct {
+			Error *safe.Failure `json:"error"`
+		}{failure})
+		if err != nil {
+			log.Println("error marshalling failure")
+			return failure
+		}
+		fmt.Fprintln(jsonOut, string(jsonContents))
+		return failure
+	}
+
+	if failure.Code == "" {
+		if failure.Reason != "" {
+			log.Printf("transaction reverted: %s\n", failure.Reason)
+		} else {
+			log.Printf("forge failed without a revert or safe error code in its output\n")
+		}
+		return failure
+	}
+	log.Printf("transaction reverted with %s: %s\n", failure.Code, failure.Description)
+	if failure.Explanation != "" {
+		log.Printf("    %s\n", shell.Highlight(failure.Explanation))
+	}
+	return failure
+}
+
+func readOwners(r shell.Runner, rpcUrl, safeAddr string) ([]string, error) {
+	out, err := cast.Call(r, rpcUrl, safeAddr, "getOwners()")
+	if err != nil {
+		return nil, err
+	}
+	return parseOwners(out)
+}
+
+func readThreshold(r shell.Runner, rpcUrl, safeAddr string) (int, error) {
+	out, err := cast.Call(r, rpcUrl, safeAddr, "getThreshold()(uint256)")
+	if err != nil {
+		return 0, err
+	}
+	fields := strings.Fields(out)
+	if len(fields) == 0 {
+		return 0, fmt.Errorf("result has invalid format")
+	}
+	// cast may annotate numbers, e.g. "2 [2e0]"
+	return strconv.Atoi(fields[0])
+}
+
+// parseOwners decodes the raw address[] returned by getOwners().
+func parseOwners(out string) ([]string, error) {
+	out = strings.TrimSpace(out)
+
+	if !strings.HasPrefix(out, "0x") {
+		return nil, fmt.Errorf("result has invalid format")
+	}
+
+	hex := out[2:]
+	if len(hex)%64 != 0 {
+		return nil, fmt.Errorf("result has invalid format")
+	}
+
+	// skip first two 64-byte chunks
+	var owners []string
+	for i := 2 * 64; i < len(hex); i += 64 {
+		addr := common.HexToAddress(hex[i : i+64])
+		owners = append(owners, strings.ToLower(addr.String()))
+	}
+	return owners, nil
+}
+
+func printExecuteInstructions(jsonFile string, tx *txstate.TxState, useRpcUrl string) {
+	presignerCmd := fmt.Sprintf(`go run presigner.go \
+    --json-file %s \
+    --private-key $EXECUTORKEY \
+    execute`, jsonFile)
+	castCmd := fmt.Sprintf(
+		`SAFE_ADDR=%s
+CALLDATA=%s
+EXECUTORKEY=********
+cast send \
+    --rpc-url %s \
+    --chain %s \
+    --private-key $EXECUTORKEY \
+    $SAFE_ADDR \
+    $CALLDATA`,
+		tx.SafeAddr, tx.Calldata, useRpcUrl, tx.ChainId)
+
+	log.Printf(`
+
+transaction now can be sent to network with:
 
 - - 8< - -
 
@@ -588,68 +2037,62 @@ transaction now can be sent to network with:
 		shell.Highlight(presignerCmd), shell.Highlight(castCmd))
 }
 
-func createOneLiner(onelinerName string, tx *TxState) {
-	contents := fmt.Sprintf(`
-echo -n "checking for rust... "
-RUST_VERSION=$(rustc -V 2> /dev/null || echo none)
-echo $RUST_VERSION
-if [ "$x" = "none" ]; then
-  echo "install rust with:"
-  echo "curl --proto '=https' --tlsv1.2 -sSf https://sh.rustup.rs | sh"
-  exit 1
-fi
-
-echo -n "checking for cast... "
-CAST_VERSION=$(cast -V 2> /dev/null || echo none)
-echo $CAST_VERSION
-if [ "$x" = "none" ]; then
-  echo "install cast with:"
-  echo "curl -L https://foundry.paradigm.xyz | bash && foundryup"
-  exit 1
-fi
-
-
-SAFE_ADDR=%s
-CALLDATA=%s
-CHAIN_ID=%s
-
-CAST_CMD="cast send --chain $CHAIN_ID $SAFE_ADDR $CALLDATA $*"
-
-echo calling: $CAST_CMD
-echo "- - - press ENTER to continue or CTRL-C to abort - - -"
-read
-
-echo sending transaction...
-$CAST_CMD
-`, tx.SafeAddr, tx.Calldata, tx.ChainId)
-
-	base64Encoded := make([]byte, base64.StdEncoding.EncodedLen(len(contents)))
-	base64.StdEncoding.Encode(base64Encoded, []byte(contents))
-	shell.WriteFile(onelinerName, base64Encoded)
+func createOneLiner(env *envelope.Envelope, onelinerName string, tx *txstate.TxState) error {
+	contents := oneliner.Render(&oneliner.Oneliner{
+		SafeAddr:  tx.SafeAddr,
+		SafeNonce: tx.SafeNonce,
+		Calldata:  tx.Calldata,
+		ChainId:   tx.ChainId,
+	})
+	sealed, err := env.Seal(oneliner.Encode(contents))
+	if err != nil {
+		return fmt.Errorf("error encrypting oneliner: %w", encryptionHint(err))
+	}
+	shell.WriteFile(onelinerName, sealed)
+	return nil
 }
 
-func writeTxState(file string, tx *TxState) {
+// writeTxState writes the transaction file, encrypted if env is enabled.
+func writeTxState(env *envelope.Envelope, file string, tx *txstate.TxState) error {
 	jsonContents, err := json.Marshal(tx)
 	if err != nil {
-		log.Println("error marshalling tx state")
-		os.Exit(1)
+		return fmt.Errorf("error marshalling tx state: %w", err)
+	}
+	sealed, err := env.Seal(jsonContents)
+	if err != nil {
+		return fmt.Errorf("error encrypting tx state: %w", encryptionHint(err))
 	}
-	shell.WriteFile(file, jsonContents)
+	shell.WriteFile(file, sealed)
+	return nil
 }
 
-func readTxState(file string) *TxState {
-	var tx TxState
-	jsonContents, err := os.ReadFile(file)
+// encryptionHint adds the flags to use to an error of a refused plaintext write.
+func encryptionHint(err error) error {
+	if errors.Is(err, envelope.ErrPlaintext) {
+		return fmt.Errorf("%w, encrypt it with --encrypt-to or --encrypt-passphrase", err)
+	}
+	return err
+}
+
+// readTxState reads the transaction file, decrypted with env if it is encrypted.
+// Once an encrypted file was read, the files written from it must be encrypted as well.
+func readTxState(env *envelope.Envelope, file string) (*txstate.TxState, error) {
+	contents, err := os.ReadFile(file)
 	if err != nil {
-		log.Printf("error reading tx state: %v\n", err)
-		os.Exit(1)
+		return nil, fmt.Errorf("error reading tx state: %w", err)
 	}
-	err = json.Unmarshal(jsonContents, &tx)
+	if envelope.IsEncrypted(contents) && env != nil {
+		env.RequireEncryption = true
+	}
+	jsonContents, err := env.Open(contents)
 	if err != nil {
-		log.Printf("error unmarshalling tx state: %v\n", err)
-		os.Exit(1)
+		return nil, fmt.Errorf("error decrypting tx state: %w", err)
+	}
+	tx, err := txstate.Parse(jsonContents)
+	if err != nil {
+		return nil, fmt.Errorf("error unmarshalling tx state: %w", err)
 	}
-	return &tx
+	return tx, nil
 }
 
 func extractFilename(filename, newState string, signer string) (string, error) {
@@ -0,0 +1,924 @@
+package main
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/ethereum-optimism/presigner/pkg/envelope"
+	"github.com/ethereum-optimism/presigner/pkg/oneliner"
+	"github.com/ethereum-optimism/presigner/pkg/safe"
+	"github.com/ethereum-optimism/presigner/pkg/shell"
+	"github.com/ethereum-optimism/presigner/pkg/txstate"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+// the values of the recorded commands in testdata
+const (
+	testRpcUrl    = "https://eth.llamarpc.com"
+	testSafeAddr  = "0x1111111111111111111111111111111111111111"
+	testTarget    = "0x2222222222222222222222222222222222222222"
+	testOwner     = "0x2c7536e3605d9c16a7a3d7b1898e529396a65c23"
+	testData      = "0x1901c5d3ba30d3ac69f3f095a61e99369d9450502ca0c2f4768b2c39ee277faa631da6790d66da1d2a209ce21a198ec78ee13a5b7ccf6c756db6ba4e84da2021f9a2"
+	testSignature = "89034eb4908db98348c63bf96932eff0c8c2dca55813f89f2f87f768d660e12754cfe6ab6a00696137d8e95f1e348f04f54a4ef2dc05fbc6054c61ffa3f347081b"
+	testSignedTx  = "draft-5.signer-" + testOwner + ".json"
+)
+
+// replayer returns the calls recorded in testdata/cassette, or no calls if cassette is empty.
+func replayer(t *testing.T, cassette string) *shell.Replayer {
+	t.Helper()
+	if cassette == "" {
+		return &shell.Replayer{}
+	}
+	r, err := shell.LoadReplayer(filepath.Join("testdata", cassette))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return r
+}
+
+// copyTx copies the transaction file testdata/tx/name to dir as newName.
+func copyTx(t *testing.T, dir, name, newName string) string {
+	t.Helper()
+	contents, err := os.ReadFile(filepath.Join("testdata", "tx", name))
+	if err != nil {
+		t.Fatal(err)
+	}
+	file := filepath.Join(dir, newName)
+	if err := os.WriteFile(file, contents, 0600); err != nil {
+		t.Fatal(err)
+	}
+	return file
+}
+
+func testFlags(jsonFile string) *cmdFlags {
+	return &cmdFlags{
+		workdir:               ".",
+		jsonFile:              jsonFile,
+		envelope:              &envelope.Envelope{},
+		delegatecallAllowlist: safe.Multicall3Address,
+	}
+}
+
+func readTestTx(t *testing.T, file string) *txstate.TxState {
+	t.Helper()
+	tx, err := readTxState(&envelope.Envelope{}, file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return tx
+}
+
+func checkError(t *testing.T, err error, wantErr string, wantInvalid bool) {
+	t.Helper()
+	if wantErr == "" {
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		return
+	}
+	if err == nil || !strings.Contains(err.Error(), wantErr) {
+		t.Fatalf("expected error containing %q, got %v", wantErr, err)
+	}
+	var invalidErr *invalidError
+	if isInvalid := errors.As(err, &invalidErr); isInvalid != wantInvalid {
+		t.Fatalf("expected invalid transaction %v, got %v: %v", wantInvalid, isInvalid, err)
+	}
+}
+
+func TestCreateTx(t *testing.T) {
+	tests := []struct {
+		name     string
+		cassette string
+		calls    []shell.Call
+		modify   func(f *cmdFlags)
+		wantErr  string
+	}{
+		{
+			name:     "pause",
+			cassette: "create.json",
+		},
+		{
+			name:    "missing target",
+			modify:  func(f *cmdFlags) { f.targetAddr = "" },
+			wantErr: "missing one of the required create parameter",
+		},
+		{
+			name:    "invalid target",
+			modify:  func(f *cmdFlags) { f.targetAddr = "0x2222" },
+			wantErr: "invalid target address",
+		},
+		{
+			name:    "invalid parameter",
+			modify:  func(f *cmdFlags) { f.paramSpecs = []string{"PAUSE_IDENTIFIER"} },
+			wantErr: "expected NAME=VALUE",
+		},
+		{
+			name:    "delegatecall not allowed",
+			modify:  func(f *cmdFlags) { f.delegatecallAllowlist = "" },
+			wantErr: "delegatecall to",
+		},
+		{
+			name: "generic without calls",
+			modify: func(f *cmdFlags) {
+				f.scriptName = "CallGeneric"
+				f.targetAddr = ""
+			},
+			wantErr: "missing one of the required create parameter for CallGeneric",
+		},
+		{
+			name: "forge fails",
+			calls: []shell.Call{{
+				Name: "forge",
+				Args: []string{"script", "CallPause", "--sig", "sign()", "--rpc-url", testRpcUrl, "--chain-id", "1", "--via-ir"},
+				Env: []string{"SAFE_ADDR=" + testSafeAddr, "SAFE_NONCE=", "TARGET_ADDR=" + testTarget,
+					"PAUSE_IDENTIFIER=presigner", "TARGET_ADDRS=" + testTarget, "CALLS="},
+				Stderr:   "Error: Compiler run failed\n",
+				ExitCode: 1,
+			}},
+			wantErr: "error running forge",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			jsonFile := filepath.Join(t.TempDir(), "draft-5.json")
+			f := testFlags(jsonFile)
+			f.scriptName = "CallPause"
+			f.safeAddr = testSafeAddr
+			f.targetAddr = testTarget
+			if tt.modify != nil {
+				tt.modify(f)
+			}
+
+			r := replayer(t, tt.cassette)
+			if tt.calls != nil {
+				r = &shell.Replayer{Calls: tt.calls}
+			}
+
+			err := createTx(r, f)
+			checkError(t, err, tt.wantErr, false)
+			if err != nil {
+				return
+			}
+			tx := readTestTx(t, jsonFile)
+			if tx.SafeNonce != "5" || tx.Data != testData || tx.TargetAddr != testTarget {
+				t.Fatalf("unexpected transaction: nonce %s, data %s, target %s", tx.SafeNonce, tx.Data, tx.TargetAddr)
+			}
+			if tx.Params["PAUSE_IDENTIFIER"] != "presigner" {
+				t.Fatalf("default parameter not set: %v", tx.Params)
+			}
+			if len(tx.Signatures) != 0 {
+				t.Fatalf("draft has signatures: %v", tx.Signatures)
+			}
+		})
+	}
+}
+
+func TestSignTx(t *testing.T) {
+	signerArgs := []string{"--private-key", "********", "--workdir", ".", "--address"}
+	tests := []struct {
+		name        string
+		cassette    string
+		calls       []shell.Call
+		modify      func(f *cmdFlags)
+		txData      string
+		wantErr     string
+		wantInvalid bool
+	}{
+		{
+			name:     "private key",
+			cassette: "sign.json",
+		},
+		{
+			name:        "other data",
+			cassette:    "sign.json",
+			txData:      testData[:len(testData)-2] + "00",
+			wantErr:     "the simulation data differs from the transaction data",
+			wantInvalid: true,
+		},
+		{
+			name:    "eip712sign fails",
+			calls:   []shell.Call{{Name: "eip712sign", Args: signerArgs, ExitCode: 1}},
+			wantErr: "error running eip712sign",
+		},
+		{
+			name:    "no signer",
+			calls:   []shell.Call{{Name: "eip712sign", Args: signerArgs, Stdout: "\n"}},
+			wantErr: "invalid output from eip712sign",
+		},
+		{
+			name:    "delegatecall not allowed",
+			modify:  func(f *cmdFlags) { f.delegatecallAllowlist = "" },
+			wantErr: "refusing to sign",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			f := testFlags(copyTx(t, dir, "draft-5.json", "draft-5.json"))
+			f.privateKey = "0xdeadbeef"
+			if tt.modify != nil {
+				tt.modify(f)
+			}
+			if tt.txData != "" {
+				tx := readTestTx(t, f.jsonFile)
+				tx.Data = tt.txData
+				if err := writeTxState(f.envelope, f.jsonFile, tx); err != nil {
+					t.Fatal(err)
+				}
+			}
+			r := replayer(t, tt.cassette)
+			if tt.calls != nil {
+				r = &shell.Replayer{Calls: tt.calls}
+			}
+
+			err := signTx(r, f)
+			checkError(t, err, tt.wantErr, tt.wantInvalid)
+			if err != nil {
+				return
+			}
+			tx := readTestTx(t, filepath.Join(dir, testSignedTx))
+			if len(tx.Signatures) != 1 || tx.Signatures[0].Signer != testOwner || tx.Signatures[0].Signature != testSignature {
+				t.Fatalf("unexpected signatures: %v", tx.Signatures)
+			}
+		})
+	}
+}
+
+func TestMergeTx(t *testing.T) {
+	tests := []struct {
+		name    string
+		others  []string
+		wantErr string
+	}{
+		{
+			name:   "signature",
+			others: []string{testSignedTx},
+		},
+		{
+			name:   "same signature twice",
+			others: []string{testSignedTx, testSignedTx},
+		},
+		{
+			name:    "other nonce",
+			others:  []string{"draft-6.json"},
+			wantErr: "nonce mismatch",
+		},
+		{
+			name:    "missing file",
+			others:  []string{"draft-7.json"},
+			wantErr: "error reading tx state",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			f := testFlags(copyTx(t, dir, "draft-5.json", "draft-5.json"))
+			var others []string
+			for _, other := range tt.others {
+				others = append(others, filepath.Join("testdata", "tx", other))
+			}
+
+			err := mergeTx(f, others)
+			checkError(t, err, tt.wantErr, false)
+			if err != nil {
+				return
+			}
+			tx := readTestTx(t, f.jsonFile)
+			if len(tx.Signatures) != 1 || tx.Signatures[0].Signer != testOwner {
+				t.Fatalf("unexpected signatures: %v", tx.Signatures)
+			}
+		})
+	}
+}
+
+func TestMergeTxChecksummedSigner(t *testing.T) {
+	dir := t.TempDir()
+	f := testFlags(copyTx(t, dir, testSignedTx, "draft-5.json"))
+	other := readTestTx(t, filepath.Join("testdata", "tx", testSignedTx))
+	other.Signatures[0].Signer = common.HexToAddress(testOwner).Hex()
+	otherFile := filepath.Join(dir, "other.json")
+	if err := writeTxState(f.envelope, otherFile, other); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := mergeTx(f, []string{otherFile}); err != nil {
+		t.Fatal(err)
+	}
+	tx := readTestTx(t, f.jsonFile)
+	if len(tx.Signatures) != 1 || tx.Signatures[0].Signer != testOwner {
+		t.Fatalf("expected a single signature of %s, got %v", testOwner, tx.Signatures)
+	}
+}
+
+func TestEncryptedTx(t *testing.T) {
+	armored := "-----BEGIN AGE ENCRYPTED FILE-----\nYWdlLWVuY3J5cHRpb24ub3JnL3Yx\n-----END AGE ENCRYPTED FILE-----\n"
+	draft, err := os.ReadFile(filepath.Join("testdata", "tx", "draft-5.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	jsonFile := filepath.Join(t.TempDir(), "draft-5.json")
+	if err := os.WriteFile(jsonFile, []byte(armored), 0600); err != nil {
+		t.Fatal(err)
+	}
+	f := testFlags(jsonFile)
+	f.envelope.Runner = &shell.Replayer{Calls: []shell.Call{
+		{Name: "age", Args: []string{"--decrypt"}, Stdin: armored, Stdout: string(draft)},
+	}}
+
+	err = mergeTx(f, []string{filepath.Join("testdata", "tx", testSignedTx)})
+	if !errors.Is(err, envelope.ErrPlaintext) {
+		t.Fatalf("expected the plaintext write to be refused, got %v", err)
+	}
+	if contents, _ := os.ReadFile(jsonFile); string(contents) != armored {
+		t.Fatalf("encrypted file was overwritten: %s", contents)
+	}
+}
+
+func TestVerifyTx(t *testing.T) {
+	hash := crypto.Keccak256Hash(hexutil.MustDecode(testData))
+	tests := []struct {
+		name        string
+		cassette    string
+		calls       []shell.Call
+		txFile      string
+		wantErr     string
+		wantInvalid bool
+	}{
+		{
+			name:     "valid",
+			cassette: "verify.json",
+			txFile:   testSignedTx,
+		},
+		{
+			name:        "reverted",
+			cassette:    "verify-reverted.json",
+			txFile:      testSignedTx,
+			wantErr:     "signatures are invalid",
+			wantInvalid: true,
+		},
+		{
+			name:     "compiler error",
+			cassette: "verify-compile-error.json",
+			txFile:   testSignedTx,
+			wantErr:  "exited with code 1",
+		},
+		{
+			name: "no signatures",
+			calls: []shell.Call{
+				ownersCall(testSafeAddr, testOwner),
+				approvedHashesCall(testSafeAddr, testOwner, hash, false),
+			},
+			txFile:  "draft-5.json",
+			wantErr: "no signatures found",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := testFlags(copyTx(t, t.TempDir(), tt.txFile, "draft-5.json"))
+			r := replayer(t, tt.cassette)
+			if tt.calls != nil {
+				r = &shell.Replayer{Calls: tt.calls}
+			}
+			checkError(t, verifyTx(r, f), tt.wantErr, tt.wantInvalid)
+		})
+	}
+}
+
+func TestVerifyTxJSON(t *testing.T) {
+	f := testFlags(copyTx(t, t.TempDir(), testSignedTx, "draft-5.json"))
+	var out bytes.Buffer
+	f.jsonOut = &out
+
+	checkError(t, verifyTx(replayer(t, "verify-reverted.json"), f), "signatures are invalid", true)
+	var failure struct {
+		Error *safe.Failure `json:"error"`
+	}
+	if err := json.Unmarshal(out.Bytes(), &failure); err != nil {
+		t.Fatalf("output is not a JSON failure: %v\n%s", err, out.String())
+	}
+	if failure.Error == nil || failure.Error.Code != "GS026" {
+		t.Fatalf("unexpected failure: %s", out.String())
+	}
+}
+
+func TestSimulateTx(t *testing.T) {
+	tests := []struct {
+		name        string
+		cassette    string
+		anvil       bool
+		wantErr     string
+		wantInvalid bool
+	}{
+		{
+			name:     "simulate",
+			cassette: "simulate.json",
+		},
+		{
+			name:        "reverted",
+			cassette:    "simulate-reverted.json",
+			wantErr:     "simulation failed",
+			wantInvalid: true,
+		},
+		{
+			name:     "anvil fork",
+			cassette: "simulate-anvil.json",
+			anvil:    true,
+		},
+		{
+			name:        "anvil fork without effect",
+			cassette:    "simulate-anvil-not-paused.json",
+			anvil:       true,
+			wantErr:     "to be true after execution, got false",
+			wantInvalid: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			f := testFlags(copyTx(t, dir, testSignedTx, "draft-5.json"))
+			f.useAnvil = tt.anvil
+			f.anvilPort = "18547"
+
+			err := simulateTx(replayer(t, tt.cassette), f, false)
+			checkError(t, err, tt.wantErr, tt.wantInvalid)
+			if err != nil {
+				for _, name := range []string{"ready-5.json", "ready-5" + oneliner.Ext} {
+					if shell.ExistFile(filepath.Join(dir, name)) {
+						t.Fatalf("%s written for a failed simulation", name)
+					}
+				}
+				return
+			}
+			tx := readTestTx(t, filepath.Join(dir, "ready-5.json"))
+			if !strings.HasPrefix(tx.Calldata, "0x6a761202") {
+				t.Fatalf("calldata is not an execTransaction: %s", tx.Calldata)
+			}
+			contents, err := os.ReadFile(filepath.Join(dir, "ready-5"+oneliner.Ext))
+			if err != nil {
+				t.Fatal(err)
+			}
+			o, err := oneliner.Parse(contents)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if o.Calldata != tx.Calldata || o.SafeAddr != testSafeAddr || o.SafeNonce != "5" || o.ChainId != "1" {
+				t.Fatalf("unexpected oneliner: %+v", o)
+			}
+		})
+	}
+}
+
+// testKey returns a deterministic private key, keys with a lower index do not have lower addresses.
+func testKey(t *testing.T, i int) *ecdsa.PrivateKey {
+	t.Helper()
+	key, err := crypto.ToECDSA(crypto.Keccak256([]byte(fmt.Sprintf("presigner test key %d", i))))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return key
+}
+
+// sign returns the signature of data by key, as produced by eip712sign.
+func sign(t *testing.T, key *ecdsa.PrivateKey, data string) txstate.TxSignature {
+	t.Helper()
+	sig, err := crypto.Sign(crypto.Keccak256(hexutil.MustDecode(data)), key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sig[64] += 27
+	return txstate.TxSignature{
+		Signer:    strings.ToLower(crypto.PubkeyToAddress(key.PublicKey).Hex()),
+		Signature: hex.EncodeToString(sig),
+	}
+}
+
+func ownersCall(safeAddr string, owners ...string) shell.Call {
+	out := fmt.Sprintf("0x%064x%064x", 32, len(owners))
+	for _, owner := range owners {
+		out += hex.EncodeToString(common.LeftPadBytes(common.HexToAddress(owner).Bytes(), 32))
+	}
+	return shell.Call{
+		Name:   "cast",
+		Args:   []string{"call", safeAddr, "getOwners()", "--rpc-url", testRpcUrl},
+		Stdout: out + "\n",
+	}
+}
+
+func thresholdCall(safeAddr string, threshold int) shell.Call {
+	return shell.Call{
+		Name:   "cast",
+		Args:   []string{"call", safeAddr, "getThreshold()(uint256)", "--rpc-url", testRpcUrl},
+		Stdout: fmt.Sprintf("%d\n", threshold),
+	}
+}
+
+func approvedHashesCall(safeAddr, owner string, hash common.Hash, approved bool) shell.Call {
+	out := "0\n"
+	if approved {
+		out = "1\n"
+	}
+	return shell.Call{
+		Name:   "cast",
+		Args:   []string{"call", safeAddr, "approvedHashes(address,bytes32)(uint256)", owner, hash.Hex(), "--rpc-url", testRpcUrl},
+		Stdout: out,
+	}
+}
+
+func signers(sigs []txstate.TxSignature) []string {
+	var signers []string
+	for _, s := range sigs {
+		signers = append(signers, s.Signer)
+	}
+	return signers
+}
+
+func TestSelectQuorum(t *testing.T) {
+	var owners []string
+	var sigs []txstate.TxSignature
+	for i := 0; i < 3; i++ {
+		sig := sign(t, testKey(t, i), testData)
+		owners = append(owners, sig.Signer)
+		sigs = append(sigs, sig)
+	}
+	outsider := sign(t, testKey(t, 3), testData)
+	forged := sign(t, testKey(t, 3), testData)
+	forged.Signer = owners[0]
+	otherData := sign(t, testKey(t, 0), testData+"00")
+	checksummed := sigs[0]
+	checksummed.Signer = common.HexToAddress(checksummed.Signer).Hex()
+
+	sorted := []string{owners[0], owners[1], owners[2]}
+	for i := range sorted {
+		for j := i + 1; j < len(sorted); j++ {
+			if common.HexToAddress(sorted[j]).Big().Cmp(common.HexToAddress(sorted[i]).Big()) < 0 {
+				sorted[i], sorted[j] = sorted[j], sorted[i]
+			}
+		}
+	}
+
+	tests := []struct {
+		name       string
+		signatures []txstate.TxSignature
+		threshold  int
+		want       []string
+		dropped    []string
+	}{
+		{
+			name:       "sorted by signer",
+			signatures: sigs,
+			threshold:  3,
+			want:       sorted,
+		},
+		{
+			name:       "limited to threshold",
+			signatures: sigs,
+			threshold:  2,
+			want:       sorted[:2],
+		},
+		{
+			name:       "below threshold",
+			signatures: sigs[:1],
+			threshold:  2,
+			want:       owners[:1],
+		},
+		{
+			name:       "not an owner",
+			signatures: []txstate.TxSignature{outsider, sigs[1]},
+			threshold:  2,
+			want:       owners[1:2],
+			dropped:    []string{outsider.Signer},
+		},
+		{
+			name:       "signed by another key",
+			signatures: []txstate.TxSignature{forged, sigs[1]},
+			threshold:  2,
+			want:       owners[1:2],
+			dropped:    []string{owners[0]},
+		},
+		{
+			name:       "same owner checksummed",
+			signatures: []txstate.TxSignature{sigs[0], checksummed},
+			threshold:  2,
+			want:       owners[:1],
+			dropped:    []string{checksummed.Signer},
+		},
+		{
+			name:       "signed other data",
+			signatures: []txstate.TxSignature{otherData},
+			threshold:  1,
+			want:       nil,
+			dropped:    []string{owners[0]},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := &shell.Replayer{Calls: []shell.Call{
+				ownersCall(testSafeAddr, owners...),
+				thresholdCall(testSafeAddr, tt.threshold),
+			}}
+			tx := &txstate.TxState{SafeAddr: testSafeAddr, Data: testData, Signatures: tt.signatures}
+			q, err := selectQuorum(r, testRpcUrl, tx)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := signers(q.selected); strings.Join(got, ",") != strings.Join(tt.want, ",") {
+				t.Fatalf("expected signers %v, got %v", tt.want, got)
+			}
+			var dropped []string
+			for _, d := range q.explainContext().Dropped {
+				dropped = append(dropped, d.Signer)
+			}
+			if strings.Join(dropped, ",") != strings.Join(tt.dropped, ",") {
+				t.Fatalf("expected dropped signers %v, got %v", tt.dropped, dropped)
+			}
+		})
+	}
+}
+
+func TestAssembleSignatures(t *testing.T) {
+	hash := crypto.Keccak256Hash(hexutil.MustDecode(testData))
+	approver := "0x000000000000000000000000000000000000000a"
+	contract := "0x000000000000000000000000000000000000000b"
+	ecdsaSig := sign(t, testKey(t, 0), testData)
+	contractSig := txstate.TxSignature{Signer: contract, Signature: "0xabcdef", Type: txstate.SignatureContract}
+	approvedSig := txstate.TxSignature{Signer: approver, Signature: preValidatedSignature(approver), Type: txstate.SignatureApprovedHash}
+
+	r := &shell.Replayer{Calls: []shell.Call{
+		ownersCall(testSafeAddr, ecdsaSig.Signer, approver, contract),
+		thresholdCall(testSafeAddr, 3),
+		{
+			Name:   "cast",
+			Args:   []string{"call", common.HexToAddress(contract).Hex(), "isValidSignature(bytes,bytes)(bytes4)", testData, "0xabcdef", "--rpc-url", testRpcUrl},
+			Stdout: safe.LegacyEIP1271MagicValue + "\n",
+		},
+		approvedHashesCall(testSafeAddr, approver, hash, true),
+	}}
+	tx := &txstate.TxState{
+		SafeAddr:   testSafeAddr,
+		Data:       testData,
+		Signatures: []txstate.TxSignature{ecdsaSig, contractSig, approvedSig},
+	}
+	signatures, _, err := assembleSignatures(r, testRpcUrl, tx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// approver < contract < ecdsa signer, the contract signature data follows the 3 static parts
+	want := strings.Repeat("0", 24) + approver[2:] + strings.Repeat("0", 64) + "01" +
+		strings.Repeat("0", 24) + contract[2:] + fmt.Sprintf("%064x", 3*65) + "00" +
+		ecdsaSig.Signature +
+		fmt.Sprintf("%064x", 3) + "abcdef"
+	if signatures != want {
+		t.Fatalf("unexpected signatures:\n  got  %s\n  want %s", signatures, want)
+	}
+}
+
+func TestEthSignSignature(t *testing.T) {
+	rs := strings.Repeat("11", 64)
+	tests := []struct {
+		name      string
+		signature string
+		want      string
+		wantErr   bool
+	}{
+		{name: "v 27", signature: rs + "1b", want: rs + "1f"},
+		{name: "v 28", signature: rs + "1c", want: rs + "20"},
+		{name: "v 0", signature: rs + "00", want: rs + "1f"},
+		{name: "v 1", signature: rs + "01", want: rs + "20"},
+		{name: "already converted", signature: rs + "1f", want: rs + "1f"},
+		{name: "invalid v", signature: rs + "1d", wantErr: true},
+		{name: "too short", signature: rs, wantErr: true},
+		{name: "not hex", signature: rs + "zz", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ethSignSignature(tt.signature)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if got != tt.want {
+				t.Fatalf("expected %s, got %s", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestParseTargets(t *testing.T) {
+	first := "0x1111111111111111111111111111111111111111"
+	second := "0x2222222222222222222222222222222222222222"
+	third := "0x3333333333333333333333333333333333333333"
+	targetsFile := filepath.Join(t.TempDir(), "targets.txt")
+	if err := os.WriteFile(targetsFile, []byte("# targets\n"+second+"\n\n  "+third+"  \n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name        string
+		targetAddr  string
+		targetsFile string
+		want        []string
+		wantErr     string
+	}{
+		{name: "single", targetAddr: first, want: []string{first}},
+		{name: "list", targetAddr: first + ", " + second, want: []string{first, second}},
+		{name: "file", targetsFile: targetsFile, want: []string{second, third}},
+		{name: "address and file", targetAddr: first, targetsFile: targetsFile, want: []string{first, second, third}},
+		{name: "duplicate", targetAddr: first + "," + third + "," + first, wantErr: "duplicate target address"},
+		{name: "invalid", targetAddr: "0x1234", wantErr: "invalid target address"},
+		{name: "empty", targetAddr: " , ", wantErr: "no target address"},
+		{name: "missing file", targetsFile: targetsFile + ".missing", wantErr: "no such file"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseTargets(tt.targetAddr, tt.targetsFile)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("expected error containing %q, got %v", tt.wantErr, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if strings.Join(got, ",") != strings.Join(tt.want, ",") {
+				t.Fatalf("expected %v, got %v", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestScriptEnv(t *testing.T) {
+	other := "0x3333333333333333333333333333333333333333"
+	tests := []struct {
+		name string
+		tx   *txstate.TxState
+		want []string
+	}{
+		{
+			name: "single target",
+			tx:   &txstate.TxState{SafeAddr: testSafeAddr, SafeNonce: "5", TargetAddr: testTarget, Params: map[string]string{"PAUSE_IDENTIFIER": "other"}},
+			want: []string{"SAFE_ADDR=" + testSafeAddr, "SAFE_NONCE=5", "TARGET_ADDR=" + testTarget,
+				"PAUSE_IDENTIFIER=other", "TARGET_ADDRS=" + testTarget, "CALLS="},
+		},
+		{
+			name: "multiple targets",
+			tx:   &txstate.TxState{SafeAddr: testSafeAddr, SafeNonce: "5", TargetAddr: testTarget, TargetAddrs: []string{testTarget, other}},
+			want: []string{"SAFE_ADDR=" + testSafeAddr, "SAFE_NONCE=5", "TARGET_ADDR=" + testTarget,
+				"PAUSE_IDENTIFIER=presigner", "TARGET_ADDRS=" + testTarget + "," + other, "CALLS="},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := scriptEnv(tt.tx)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if strings.Join(got, " ") != strings.Join(tt.want, " ") {
+				t.Fatalf("expected %v, got %v", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestReadBroadcastLogs(t *testing.T) {
+	broadcast := `{"receipts":[{"transactionHash":"0xabc","status":"0x1","logs":[{"address":"` + testTarget + `","topics":[],"data":"0x"}]}]}`
+	tests := []struct {
+		name     string
+		registry string
+		dir      string
+		wantErr  string
+	}{
+		{
+			name:     "registered file",
+			registry: `{"scripts":[{"name":"CallPause","file":"pause/Pause.s.sol","requires_target":true}]}`,
+			dir:      "Pause.s.sol",
+		},
+		{
+			name: "without registry",
+			dir:  "CallPause.s.sol",
+		},
+		{
+			name:     "named after the script",
+			registry: `{"scripts":[{"name":"CallPause","file":"pause/Pause.s.sol","requires_target":true}]}`,
+			dir:      "CallPause.s.sol",
+			wantErr:  "no such file",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			workdir := t.TempDir()
+			if tt.registry != "" {
+				if err := os.MkdirAll(filepath.Join(workdir, "script"), 0700); err != nil {
+					t.Fatal(err)
+				}
+				if err := os.WriteFile(filepath.Join(workdir, "script", "registry.json"), []byte(tt.registry), 0600); err != nil {
+					t.Fatal(err)
+				}
+			}
+			dir := filepath.Join(workdir, "broadcast", tt.dir, "1")
+			if err := os.MkdirAll(dir, 0700); err != nil {
+				t.Fatal(err)
+			}
+			if err := os.WriteFile(filepath.Join(dir, "run-latest.json"), []byte(broadcast), 0600); err != nil {
+				t.Fatal(err)
+			}
+
+			logs, err := readBroadcastLogs(workdir, &txstate.TxState{ScriptName: "CallPause", ChainId: "1"})
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("expected error containing %q, got %v", tt.wantErr, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(logs) != 1 || !strings.EqualFold(logs[0].Address, testTarget) {
+				t.Fatalf("unexpected logs: %+v", logs)
+			}
+		})
+	}
+}
+
+func TestIsSafe(t *testing.T) {
+	codeCall := func(out string, exitCode int) shell.Call {
+		return shell.Call{Name: "cast", Args: []string{"code", testOwner, "--rpc-url", testRpcUrl}, Stdout: out, ExitCode: exitCode}
+	}
+	thresholdCall := func(out, stderr string, exitCode int) shell.Call {
+		return shell.Call{Name: "cast", Args: []string{"call", testOwner, "getThreshold()", "--rpc-url", testRpcUrl},
+			Stdout: out, Stderr: stderr, ExitCode: exitCode}
+	}
+	tests := []struct {
+		name    string
+		calls   []shell.Call
+		want    bool
+		wantErr string
+	}{
+		{
+			name:  "account",
+			calls: []shell.Call{codeCall("0x\n", 0)},
+		},
+		{
+			name: "safe",
+			calls: []shell.Call{
+				codeCall("0x6080\n", 0),
+				thresholdCall(fmt.Sprintf("0x%064x\n", 2), "", 0),
+			},
+			want: true,
+		},
+		{
+			name: "reverting contract",
+			calls: []shell.Call{
+				codeCall("0x6080\n", 0),
+				thresholdCall("", "Error: server returned an error response: error code 3: execution reverted\n", 1),
+			},
+		},
+		{
+			name: "contract with a fallback",
+			calls: []shell.Call{
+				codeCall("0x6080\n", 0),
+				thresholdCall("0x\n", "", 0),
+			},
+		},
+		{
+			name:    "code not available",
+			calls:   []shell.Call{codeCall("", 1)},
+			wantErr: "reading code of",
+		},
+		{
+			name: "threshold not available",
+			calls: []shell.Call{
+				codeCall("0x6080\n", 0),
+				thresholdCall("", "Error: error sending request for url\n", 1),
+			},
+			wantErr: "reading threshold of",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := isSafe(&shell.Replayer{Calls: tt.calls}, testRpcUrl, testOwner)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("expected error containing %q, got %v", tt.wantErr, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.want {
+				t.Fatalf("expected %v, got %v", tt.want, got)
+			}
+		})
+	}
+}
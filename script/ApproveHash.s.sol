@@ -0,0 +1,46 @@
+// SPDX-License-Identifier: UNLICENSED
+pragma solidity ^0.8.15;
+
+import "forge-std/console.sol";
+import "@base-contracts/script/universal/MultisigBuilder.sol";
+import {IGnosisSafe} from "@eth-optimism-bedrock/scripts/interfaces/IGnosisSafe.sol";
+
+interface IApproveHash {
+    function approveHash(bytes32 hashToApprove) external;
+    function approvedHashes(address owner, bytes32 hash) external view returns (uint256);
+}
+
+contract ApproveHash is MultisigBuilder {
+    function _postCheck() internal view override {
+        IGnosisSafe safe = IGnosisSafe(_ownerSafe());
+        console.log("Nonce post check", safe.nonce());
+        require(
+            IApproveHash(_parentSafe()).approvedHashes(_ownerSafe(), _approveHash()) == 1,
+            "ApproveHash: hash is not approved"
+        );
+    }
+
+    function _buildCalls() internal view override returns (IMulticall3.Call3[] memory) {
+        IMulticall3.Call3[] memory calls = new IMulticall3.Call3[](1);
+
+        calls[0] = IMulticall3.Call3({
+            target: _parentSafe(),
+            allowFailure: false,
+            callData: abi.encodeCall(IApproveHash.approveHash, (_approveHash()))
+        });
+
+        return calls;
+    }
+
+    function _ownerSafe() internal view override returns (address) {
+        return vm.envAddress("SAFE_ADDR");
+    }
+
+    function _parentSafe() internal view returns (address) {
+        return vm.envAddress("TARGET_ADDR");
+    }
+
+    function _approveHash() internal view returns (bytes32) {
+        return vm.envBytes32("APPROVE_HASH");
+    }
+}
@@ -0,0 +1,21 @@
+// SPDX-License-Identifier: UNLICENSED
+pragma solidity ^0.8.15;
+
+import "forge-std/console.sol";
+import "@base-contracts/script/universal/MultisigBuilder.sol";
+import {IGnosisSafe} from "@eth-optimism-bedrock/scripts/interfaces/IGnosisSafe.sol";
+
+contract CallGeneric is MultisigBuilder {
+    function _postCheck() internal view override {
+        IGnosisSafe safe = IGnosisSafe(_ownerSafe());
+        console.log("Nonce post check", safe.nonce());
+    }
+
+    function _buildCalls() internal view override returns (IMulticall3.Call3[] memory) {
+        return abi.decode(vm.envBytes("CALLS"), (IMulticall3.Call3[]));
+    }
+
+    function _ownerSafe() internal view override returns (address) {
+        return vm.envAddress("SAFE_ADDR");
+    }
+}
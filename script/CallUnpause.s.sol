@@ -10,16 +10,23 @@ contract CallUnpause is MultisigBuilder {
     function _postCheck() internal view override {
         IGnosisSafe safe = IGnosisSafe(_ownerSafe());
         console.log("Nonce post check", safe.nonce());
+        address[] memory targets = _superchainConfigAddrs();
+        for (uint256 i = 0; i < targets.length; i++) {
+            require(!Pausable(targets[i]).paused(), "CallUnpause: target is still paused");
+        }
     }
 
     function _buildCalls() internal view override returns (IMulticall3.Call3[] memory) {
-        IMulticall3.Call3[] memory calls = new IMulticall3.Call3[](1);
+        address[] memory targets = _superchainConfigAddrs();
+        IMulticall3.Call3[] memory calls = new IMulticall3.Call3[](targets.length);
 
-        calls[0] = IMulticall3.Call3({
-            target: _superchainConfigAddr(),
-            allowFailure: false,
-            callData: abi.encodeCall(Pausable.unpause, ())
-        });
+        for (uint256 i = 0; i < targets.length; i++) {
+            calls[i] = IMulticall3.Call3({
+                target: targets[i],
+                allowFailure: false,
+                callData: abi.encodeCall(Pausable.unpause, ())
+            });
+        }
 
         return calls;
     }
@@ -28,7 +35,9 @@ contract CallUnpause is MultisigBuilder {
         return vm.envAddress("SAFE_ADDR");
     }
 
-    function _superchainConfigAddr() internal view returns (address) {
-        return vm.envAddress("TARGET_ADDR");
+    function _superchainConfigAddrs() internal view returns (address[] memory) {
+        address[] memory targets = new address[](1);
+        targets[0] = vm.envAddress("TARGET_ADDR");
+        return vm.envOr("TARGET_ADDRS", ",", targets);
     }
 }
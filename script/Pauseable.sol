@@ -4,4 +4,5 @@ pragma solidity ^0.8.15;
 interface Pausable {
     function pause(string memory _identifier) external;
     function unpause() external;
+    function paused() external view returns (bool);
 }
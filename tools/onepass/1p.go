@@ -1,60 +1,162 @@
 package main
 
 import (
-	"encoding/base64"
-	"encoding/json"
+	"bufio"
+	"errors"
 	"flag"
 	"fmt"
 	"log"
 	"os"
-	"sort"
+	"strconv"
 	"strings"
+	"time"
 
+	"github.com/ethereum-optimism/presigner/pkg/cast"
+	"github.com/ethereum-optimism/presigner/pkg/envelope"
+	"github.com/ethereum-optimism/presigner/pkg/multicall"
+	"github.com/ethereum-optimism/presigner/pkg/oneliner"
+	"github.com/ethereum-optimism/presigner/pkg/safe"
+	"github.com/ethereum-optimism/presigner/pkg/secretstore"
 	"github.com/ethereum-optimism/presigner/pkg/shell"
+	"github.com/ethereum-optimism/presigner/pkg/txstate"
+	"github.com/ethereum/go-ethereum/common/hexutil"
 )
 
 func main() {
 	var workdir string
 	var path string
+	var configFile string
+	var backend string
 	var account string
 	var vault string
+	var vaultAddr string
+	var vaultMount string
+	var vaultPath string
+	var ageDir string
+	var ageRecipients string
+	var ageIdentity string
+	var chainFilter string
+	var safeFilter string
+	var yes bool
+	var keepVersions bool
+	var rpcUrl string
+	var archive string
+	var encryptTo string
+	var encryptPassphrase bool
+	var storeTimeout time.Duration
+	var castTimeout time.Duration
+
+	defaults := secretstore.DefaultConfig()
 
 	flag.StringVar(&workdir, "workdir", ".", "Workdir")
 	flag.StringVar(&path, "path", "tx/", "Path to files to be pushed or pulled")
-	flag.StringVar(&account, "account", "oplabs.1password.com", "1Password account")
-	flag.StringVar(&vault, "vault", "Pre-signed Pause", "1Password vault")
+	flag.StringVar(&configFile, "config", "", "JSON file with the secret store configuration, flags take precedence")
+	flag.StringVar(&backend, "backend", defaults.Backend, "Secret store backend: 1password, vault or age")
+	flag.StringVar(&account, "account", defaults.OnePassword.Account, "1Password account")
+	flag.StringVar(&vault, "vault", defaults.OnePassword.Vault, "1Password vault")
+	flag.StringVar(&vaultAddr, "vault-addr", "", "HashiCorp Vault address (default to VAULT_ADDR)")
+	flag.StringVar(&vaultMount, "vault-mount", defaults.Vault.Mount, "HashiCorp Vault KV v2 mount")
+	flag.StringVar(&vaultPath, "vault-path", defaults.Vault.Path, "HashiCorp Vault path of the items")
+	flag.StringVar(&ageDir, "age-dir", defaults.Age.Dir, "Directory of the age encrypted items")
+	flag.StringVar(&ageRecipients, "age-recipients", "", "Comma separated list of age recipients to encrypt to")
+	flag.StringVar(&ageIdentity, "age-identity", "", "age identity file to decrypt with")
+	flag.StringVar(&chainFilter, "chain", "", "Only list the items of this chain ID")
+	flag.StringVar(&safeFilter, "safe", "", "Only list the items of this safe address")
+	flag.BoolVar(&yes, "yes", false, "Apply the sync plan without asking for confirmation")
+	flag.StringVar(&rpcUrl, "rpc-url", "", "RPC URL used by verify to read the safe nonces, default to the rpc_url of each transaction")
+	flag.StringVar(&archive, "archive", "", "Where prune moves retired items, a 1Password vault, Vault path or age directory of the same backend, deleted if empty")
+	flag.StringVar(&encryptTo, "encrypt-to", "", "Comma separated list of age recipients to encrypt pushed files to")
+	flag.BoolVar(&encryptPassphrase, "encrypt-passphrase", false, "Encrypt pushed files with an age passphrase")
+	flag.BoolVar(&keepVersions, "version", false, "Keep the remote copy of changed items as a new version instead of overwriting it")
+	flag.DurationVar(&storeTimeout, "store-timeout", 2*time.Minute, "Timeout of the op and vault commands, 0 for none")
+	flag.DurationVar(&castTimeout, "cast-timeout", 2*time.Minute, "Timeout of the cast calls reading the safe nonces, 0 for none")
 
 	flag.Parse()
 
+	shell.Timeouts["op"] = storeTimeout
+	shell.Timeouts["vault"] = storeTimeout
+	shell.Timeouts["cast"] = castTimeout
+	shell.HandleInterrupt()
+
 	args := flag.Args()
 
 	if len(args) == 0 {
-		log.Println("no command specified, use one of: list, pull, push")
+		log.Println("no command specified, use one of: list, pull, push, sync, verify, show, prune")
 		flag.PrintDefaults()
 		os.Exit(1)
 	}
 	cmd := args[0]
 
+	config := defaults
+	if configFile != "" {
+		var err error
+		config, err = secretstore.LoadConfig(configFile)
+		if err != nil {
+			log.Printf("error reading config: %v\n", err)
+			os.Exit(1)
+		}
+	}
+	// flags given explicitly override the config file
+	flag.Visit(func(f *flag.Flag) {
+		switch f.Name {
+		case "backend":
+			config.Backend = backend
+		case "account":
+			config.OnePassword.Account = account
+		case "vault":
+			config.OnePassword.Vault = vault
+		case "vault-addr":
+			config.Vault.Address = vaultAddr
+		case "vault-mount":
+			config.Vault.Mount = vaultMount
+		case "vault-path":
+			config.Vault.Path = vaultPath
+		case "age-dir":
+			config.Age.Dir = ageDir
+		case "age-recipients":
+			config.Age.Recipients = strings.Split(ageRecipients, ",")
+		case "age-identity":
+			config.Age.Identity = ageIdentity
+		}
+	})
+
+	runner := &shell.Exec{Workdir: workdir}
+	store, err := config.Open(runner, workdir)
+	if err != nil {
+		log.Printf("error opening secret store: %v\n", err)
+		os.Exit(1)
+	}
+
+	// files encrypted by presigner are decrypted with the same identity as the age backend
+	env := &envelope.Envelope{Runner: runner, Passphrase: encryptPassphrase, Identity: ageIdentity}
+	if encryptTo != "" {
+		env.Recipients = strings.Split(encryptTo, ",")
+	}
+	// reads decrypted contents, and encrypts pushed ones; pull and prune move items as stored
+	encrypted := &secretstore.Encrypted{Store: store, Envelope: env}
+
 	if cmd == "list" {
-		outBuffer, _, err := shell.Run(workdir, "op", []string{}, "", true,
-			"--format", "json",
-			"--account", account,
-			"--vault", vault,
-			"item",
-			"list")
-		if err != nil {
-			log.Printf("error running op: %v\n", err)
+		items, err := store.List()
+		if err != nil {
+			log.Printf("error listing items: %v\n", err)
 			os.Exit(1)
 		}
-		var j []map[string]interface{}
-		json.Unmarshal(outBuffer, &j)
-		items := make([]string, 0, len(j))
-		for _, jitem := range j {
-			items = append(items, strings.TrimSpace(jitem["title"].(string)))
+		filters := make(map[string]string)
+		if chainFilter != "" {
+			filters[txstate.MetadataChain] = chainFilter
+		}
+		if safeFilter != "" {
+			filters[txstate.MetadataSafe] = safeFilter
 		}
-		sort.Strings(items)
 		for _, item := range items {
-			fmt.Println(item)
+			if !item.Matches(filters) {
+				continue
+			}
+			if len(item.Metadata) == 0 {
+				fmt.Println(item.Name)
+			} else {
+				fmt.Printf("%s  %s\n", item.Name, secretstore.FormatMetadata(item.Metadata))
+			}
 		}
 	} else if cmd == "pull" {
 		if len(args) != 2 {
@@ -63,20 +165,12 @@ func main() {
 			os.Exit(1)
 		}
 		item := args[1]
-		outBuffer, _, err := shell.Run(workdir, "op", []string{}, "", true,
-			"--account", account,
-			"read",
-			fmt.Sprintf("op://%s/%s/text", vault, item))
+		contents, err := store.Get(item)
 		if err != nil {
-			log.Printf("error running op: %v\n", err)
+			log.Printf("error reading item: %v\n", err)
 			os.Exit(1)
 		}
-		decoded, err := base64.StdEncoding.DecodeString(string(outBuffer))
-		if err != nil {
-			log.Printf("error decoding base64: %v\n", err)
-			os.Exit(1)
-		}
-		shell.WriteFile(fmt.Sprintf("%s/%s", path, item), decoded)
+		shell.WriteFile(fmt.Sprintf("%s/%s", path, item), contents)
 	} else if cmd == "push" {
 		if len(args) != 2 {
 			log.Println("use: push <item>")
@@ -86,40 +180,468 @@ func main() {
 		item := args[1]
 
 		// check for existence
-		_, errBuffer, err := shell.Run(workdir, "op", []string{}, "", true,
-			"--account", account,
-			"read",
-			fmt.Sprintf("op://%s/%s/text", vault, item))
-		if err != nil {
-			log.Printf("error running op: %v\n", err)
-			os.Exit(1)
-		}
-		if !strings.Contains(string(errBuffer), "isn't an item") {
+		_, err := store.Get(item)
+		if err == nil {
 			fmt.Println("item already exists, exiting")
 			os.Exit(255)
 		}
+		if !errors.Is(err, secretstore.ErrNotFound) {
+			log.Printf("error reading item: %v\n", err)
+			os.Exit(1)
+		}
 
 		contents, err := os.ReadFile(fmt.Sprintf("%s/%s", path, item))
 		if err != nil {
 			log.Printf("error reading file: %v\n", err)
 			os.Exit(1)
 		}
-		b64 := base64.StdEncoding.EncodeToString(contents)
-		_, _, err = shell.Run(workdir, "op", []string{}, "", true,
-			"--account", account,
-			"--vault", vault,
-			"item",
-			"create",
-			"--title", item,
-			"--category", "Login",
-			fmt.Sprintf("text=%s", b64))
-		if err != nil {
-			log.Printf("error running op: %v\n", err)
+		metadata := readMetadata(env, path, item, contents)
+		if err := encrypted.Put(item, contents, metadata); err != nil {
+			log.Printf("error writing item: %v\n", err)
+			os.Exit(1)
+		}
+	} else if cmd == "sync" {
+		plan, err := secretstore.Plan(store, path, env)
+		if err != nil {
+			log.Printf("error comparing %s with the secret store: %v\n", path, err)
+			os.Exit(1)
+		}
+		plan.Print(keepVersions)
+		if plan.Empty() {
+			return
+		}
+		if !yes && !confirm("apply?") {
+			fmt.Println("not applied")
+			os.Exit(255)
+		}
+		err = plan.Apply(store, path, env, keepVersions, func(item string, contents []byte) map[string]string {
+			return readMetadata(env, path, item, contents)
+		})
+		if err != nil {
+			log.Printf("error applying sync plan: %v\n", err)
+			os.Exit(1)
+		}
+	} else if cmd == "show" {
+		if len(args) != 2 {
+			log.Println("use: show <item>")
+			flag.PrintDefaults()
+			os.Exit(1)
+		}
+		if err := showOneliner(encrypted, runner, rpcUrl, args[1]); err != nil {
+			log.Printf("error showing %s: %v\n", args[1], err)
+			os.Exit(1)
+		}
+	} else if cmd == "prune" {
+		var archiveStore secretstore.SecretStore
+		if archive != "" {
+			archiveStore, err = config.WithLocation(archive).Open(runner, workdir)
+			if err != nil {
+				log.Printf("error opening archive: %v\n", err)
+				os.Exit(1)
+			}
+		}
+		retired, err := findRetired(encrypted, runner, rpcUrl)
+		if err != nil {
+			log.Printf("error finding retired items: %v\n", err)
+			os.Exit(1)
+		}
+		if len(retired) == 0 {
+			fmt.Println("nothing to prune")
+			return
+		}
+		action := "delete"
+		if archive != "" {
+			action = "archive to " + archive
+		}
+		for _, r := range retired {
+			fmt.Printf("%s  %s (%s)\n", action, r.item.Name, r.reason)
+		}
+		if !yes && !confirm("apply?") {
+			fmt.Println("not applied")
+			os.Exit(255)
+		}
+		if !pruneItems(store, archiveStore, retired) {
 			os.Exit(1)
 		}
+	} else if cmd == "verify" {
+		if !verifyItems(encrypted, runner, rpcUrl) {
+			os.Exit(255)
+		}
 	} else {
-		log.Println("unknown command, use one of: list, pull, push")
+		log.Println("unknown command, use one of: list, pull, push, sync, verify, show, prune")
 		flag.PrintDefaults()
 		os.Exit(1)
 	}
 }
+
+// readMetadata describes the transaction of an item, read from the item itself
+// or, for a oneliner, from the JSON file it was created from.
+func readMetadata(env *envelope.Envelope, path, item string, contents []byte) map[string]string {
+	if name, ok := strings.CutSuffix(item, oneliner.Ext); ok {
+		var err error
+		contents, err = os.ReadFile(fmt.Sprintf("%s/%s.json", path, name))
+		if err != nil {
+			log.Printf("no metadata for %s: %v\n", item, err)
+			return nil
+		}
+	}
+	contents, err := env.Open(contents)
+	if err != nil {
+		log.Printf("no metadata for %s: %v\n", item, err)
+		return nil
+	}
+	tx, err := txstate.Parse(contents)
+	if err != nil {
+		log.Printf("no metadata for %s: not a transaction file\n", item)
+		return nil
+	}
+	return tx.Metadata()
+}
+
+// confirm asks a yes or no question on the terminal.
+func confirm(question string) bool {
+	fmt.Printf("%s [y/N] ", question)
+	answer, _ := bufio.NewReader(os.Stdin).ReadString('\n')
+	answer = strings.ToLower(strings.TrimSpace(answer))
+	return answer == "y" || answer == "yes"
+}
+
+// verifyItems pulls every transaction and oneliner in memory and reports the ones
+// that are corrupted, stale or do not match their sibling, it returns true if all are valid.
+func verifyItems(store secretstore.SecretStore, r shell.Runner, rpcUrl string) bool {
+	items, err := store.List()
+	if err != nil {
+		log.Printf("error listing items: %v\n", err)
+		return false
+	}
+
+	valid := true
+	report := func(status, item, detail string) {
+		if status != "ok" {
+			valid = false
+		}
+		if detail == "" {
+			fmt.Printf("%-10s %s\n", status, item)
+		} else {
+			fmt.Printf("%-10s %s  %s\n", status, item, detail)
+		}
+	}
+
+	nonces := newSafeNonces(r, rpcUrl)
+	checkNonce := func(item string, tx *txstate.TxState) {
+		current, err := nonces.get(tx.RpcUrl, tx.SafeAddr)
+		if err != nil {
+			fmt.Printf("%-10s %s  could not read the nonce of safe %s: %v\n", "warning", item, tx.SafeAddr, err)
+			return
+		}
+		nonce, _ := strconv.ParseUint(tx.SafeNonce, 10, 64)
+		if nonce < current {
+			report("STALE", item, fmt.Sprintf("nonce %d already consumed, safe nonce is %d", nonce, current))
+			return
+		}
+		report("ok", item, "")
+	}
+
+	txs := make(map[string]*txstate.TxState)
+	var oneliners []string
+	for _, item := range items {
+		if strings.HasSuffix(item.Name, oneliner.Ext) {
+			oneliners = append(oneliners, item.Name)
+			continue
+		}
+		name, ok := strings.CutSuffix(item.Name, ".json")
+		if !ok {
+			fmt.Printf("%-10s %s  not a transaction or oneliner\n", "skipped", item.Name)
+			continue
+		}
+		contents, err := store.Get(item.Name)
+		if err != nil {
+			report("CORRUPTED", item.Name, err.Error())
+			continue
+		}
+		tx, err := txstate.Parse(contents)
+		if err != nil {
+			report("CORRUPTED", item.Name, err.Error())
+			continue
+		}
+		if err := tx.Validate(); err != nil {
+			report("CORRUPTED", item.Name, err.Error())
+			continue
+		}
+		txs[name] = tx
+		checkNonce(item.Name, tx)
+	}
+
+	for _, item := range oneliners {
+		contents, err := store.Get(item)
+		if err != nil {
+			report("CORRUPTED", item, err.Error())
+			continue
+		}
+		o, err := oneliner.Parse(contents)
+		if err != nil {
+			report("CORRUPTED", item, err.Error())
+			continue
+		}
+		if _, err := safe.DecodeExecTransaction(o.Calldata); err != nil {
+			report("CORRUPTED", item, err.Error())
+			continue
+		}
+		tx, ok := txs[strings.TrimSuffix(item, oneliner.Ext)]
+		if !ok {
+			report("MISMATCH", item, "no valid sibling JSON")
+			continue
+		}
+		if !strings.EqualFold(o.SafeAddr, tx.SafeAddr) || o.ChainId != tx.ChainId {
+			report("MISMATCH", item, fmt.Sprintf("safe %s on chain %s, sibling has safe %s on chain %s", o.SafeAddr, o.ChainId, tx.SafeAddr, tx.ChainId))
+			continue
+		}
+		if o.SafeNonce != "" && o.SafeNonce != tx.SafeNonce {
+			report("MISMATCH", item, fmt.Sprintf("nonce %s, sibling has nonce %s", o.SafeNonce, tx.SafeNonce))
+			continue
+		}
+		if !strings.EqualFold(o.Calldata, tx.Calldata) {
+			report("MISMATCH", item, "calldata differs from the sibling JSON")
+			continue
+		}
+		checkNonce(item, tx)
+	}
+	return valid
+}
+
+// showOneliner decodes a oneliner in memory and prints what it would send,
+// with the nonce it was created for compared to the current nonce of the safe.
+func showOneliner(store secretstore.SecretStore, r shell.Runner, rpcUrl, item string) error {
+	if !strings.HasSuffix(item, oneliner.Ext) {
+		return fmt.Errorf("not a oneliner, expected %s extension", oneliner.Ext)
+	}
+	contents, err := store.Get(item)
+	if err != nil {
+		return err
+	}
+	o, err := oneliner.Parse(contents)
+	if err != nil {
+		return err
+	}
+	execTx, err := safe.DecodeExecTransaction(o.Calldata)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("chain id:         %s\n", o.ChainId)
+	fmt.Printf("safe:             %s\n", o.SafeAddr)
+	if o.SafeNonce != "" {
+		fmt.Printf("safe nonce:       %s\n", o.SafeNonce)
+	} else {
+		fmt.Printf("safe nonce:       unknown, oneliner created without SAFE_NONCE\n")
+	}
+	fmt.Printf("operation:        %s %s\n", strings.ToUpper(execTx.OperationName()), execTx.To)
+	fmt.Printf("value:            %s\n", execTx.Value)
+	if calls, err := multicall.DecodeAggregate3(execTx.Data); err == nil {
+		for _, call := range calls {
+			fmt.Printf("call:             %s %s\n", call.Target, call.Calldata)
+			if call.AllowFailure {
+				fmt.Printf("    allow failure\n")
+			}
+		}
+	} else {
+		fmt.Printf("data:             %s\n", hexutil.Encode(execTx.Data))
+	}
+	fmt.Printf("signatures:       %d bytes\n", len(execTx.Signatures))
+	if execTx.Operation == 1 {
+		fmt.Printf("%s\n", shell.Highlight(fmt.Sprintf("!!! DELEGATECALL to %s: its code runs with the storage and balance of safe %s !!!", execTx.To, o.SafeAddr)))
+	}
+
+	// the oneliner has no RPC URL, use the one of its sibling transaction
+	var sibling *txstate.TxState
+	if siblingContents, err := store.Get(strings.TrimSuffix(item, oneliner.Ext) + ".json"); err == nil {
+		sibling, _ = txstate.Parse(siblingContents)
+	}
+	if rpcUrl == "" && sibling != nil {
+		rpcUrl = sibling.RpcUrl
+	}
+	nonce := o.SafeNonce
+	if nonce == "" && sibling != nil {
+		nonce = sibling.SafeNonce
+	}
+	if rpcUrl == "" {
+		fmt.Printf("on-chain nonce:   unknown, use --rpc-url\n")
+		return nil
+	}
+	current, err := newSafeNonces(r, rpcUrl).get(rpcUrl, o.SafeAddr)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("on-chain nonce:   %d\n", current)
+
+	embedded, err := strconv.ParseUint(nonce, 10, 64)
+	if err != nil {
+		return nil
+	}
+	switch {
+	case embedded < current:
+		fmt.Printf("%s\n", shell.Highlight("nonce already consumed, the oneliner will revert"))
+	case embedded > current:
+		fmt.Printf("%d transactions of the safe must be executed first\n", embedded-current)
+	default:
+		fmt.Printf("nonce matches, the oneliner can be executed\n")
+	}
+	return nil
+}
+
+// safeNonces reads the current nonce of safes, once per RPC URL and safe.
+type safeNonces struct {
+	runner shell.Runner
+
+	// used instead of the RPC URL of the transactions if set
+	rpcUrl string
+	cache  map[string]uint64
+}
+
+func newSafeNonces(r shell.Runner, rpcUrl string) *safeNonces {
+	return &safeNonces{runner: r, rpcUrl: rpcUrl, cache: make(map[string]uint64)}
+}
+
+func (n *safeNonces) get(rpcUrl, safeAddr string) (uint64, error) {
+	if n.rpcUrl != "" {
+		rpcUrl = n.rpcUrl
+	}
+	if rpcUrl == "" {
+		return 0, fmt.Errorf("no RPC URL, use --rpc-url")
+	}
+	key := rpcUrl + "/" + strings.ToLower(safeAddr)
+	if current, ok := n.cache[key]; ok {
+		return current, nil
+	}
+	out, err := cast.Call(n.runner, rpcUrl, safeAddr, "nonce()(uint256)")
+	if err != nil {
+		return 0, err
+	}
+	fields := strings.Fields(out)
+	if len(fields) == 0 {
+		return 0, fmt.Errorf("could not read the nonce of safe %s", safeAddr)
+	}
+	current, err := strconv.ParseUint(fields[0], 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid nonce %s", fields[0])
+	}
+	n.cache[key] = current
+	return current, nil
+}
+
+type retiredItem struct {
+	item   secretstore.Item
+	reason string
+}
+
+// findRetired returns the items of transactions marked executed by the presigner,
+// or whose nonce has been consumed by the safe, with their oneliners and versions.
+func findRetired(store secretstore.SecretStore, r shell.Runner, rpcUrl string) ([]retiredItem, error) {
+	items, err := store.List()
+	if err != nil {
+		return nil, err
+	}
+	nonces := newSafeNonces(r, rpcUrl)
+
+	// the reason a transaction is retired, by name without extension
+	reasons := make(map[string]string)
+	for _, item := range items {
+		name, ok := strings.CutSuffix(item.Name, ".json")
+		if !ok {
+			continue
+		}
+		contents, err := store.Get(item.Name)
+		if err != nil {
+			return nil, fmt.Errorf("reading %s: %w", item.Name, err)
+		}
+		tx, err := txstate.Parse(contents)
+		if err != nil {
+			log.Printf("skipping %s: %v\n", item.Name, err)
+			continue
+		}
+		if tx.ExecutedAt != "" {
+			reasons[name] = "executed at " + tx.ExecutedAt
+			continue
+		}
+		current, err := nonces.get(tx.RpcUrl, tx.SafeAddr)
+		if err != nil {
+			log.Printf("skipping %s: %v\n", item.Name, err)
+			continue
+		}
+		if nonce, err := strconv.ParseUint(tx.SafeNonce, 10, 64); err == nil && nonce < current {
+			reasons[name] = fmt.Sprintf("nonce %d consumed, safe nonce is %d", nonce, current)
+		}
+	}
+
+	// oneliners without a transaction embed their nonce, but not their RPC URL
+	if rpcUrl != "" {
+		for _, item := range items {
+			name, ok := strings.CutSuffix(item.Name, oneliner.Ext)
+			if _, retired := reasons[name]; !ok || retired {
+				continue
+			}
+			contents, err := store.Get(item.Name)
+			if err != nil {
+				return nil, fmt.Errorf("reading %s: %w", item.Name, err)
+			}
+			o, err := oneliner.Parse(contents)
+			if err != nil || o.SafeNonce == "" {
+				continue
+			}
+			current, err := nonces.get(rpcUrl, o.SafeAddr)
+			if err != nil {
+				log.Printf("skipping %s: %v\n", item.Name, err)
+				continue
+			}
+			if nonce, err := strconv.ParseUint(o.SafeNonce, 10, 64); err == nil && nonce < current {
+				reasons[name] = fmt.Sprintf("nonce %d consumed, safe nonce is %d", nonce, current)
+			}
+		}
+	}
+
+	var retired []retiredItem
+	for _, item := range items {
+		// previous versions kept by sync, e.g. name.json.v1, follow their item
+		name := secretstore.BaseName(item.Name)
+		name = strings.TrimSuffix(strings.TrimSuffix(name, ".json"), oneliner.Ext)
+		if reason, ok := reasons[name]; ok {
+			retired = append(retired, retiredItem{item: item, reason: reason})
+		}
+	}
+	return retired, nil
+}
+
+// pruneItems moves retired items to the archive, or deletes them if there is none,
+// and prints an audit summary, it returns false if any item could not be retired.
+func pruneItems(store, archiveStore secretstore.SecretStore, retired []retiredItem) bool {
+	ok := true
+	archived, deleted := 0, 0
+	fmt.Printf("\nprune summary, %s\n", time.Now().Format(time.RFC3339))
+	for _, r := range retired {
+		if archiveStore != nil {
+			contents, err := store.Get(r.item.Name)
+			if err == nil {
+				err = archiveStore.Put(r.item.Name, contents, r.item.Metadata)
+			}
+			if err != nil {
+				fmt.Printf("FAILED     %s  archiving: %v\n", r.item.Name, err)
+				ok = false
+				continue
+			}
+		}
+		if err := store.Delete(r.item.Name); err != nil {
+			fmt.Printf("FAILED     %s  deleting: %v\n", r.item.Name, err)
+			ok = false
+			continue
+		}
+		if archiveStore != nil {
+			archived++
+			fmt.Printf("archived   %s  %s %s\n", r.item.Name, r.reason, secretstore.FormatMetadata(r.item.Metadata))
+		} else {
+			deleted++
+			fmt.Printf("deleted    %s  %s %s\n", r.item.Name, r.reason, secretstore.FormatMetadata(r.item.Metadata))
+		}
+	}
+	fmt.Printf("%d archived, %d deleted, %d failed\n", archived, deleted, len(retired)-archived-deleted)
+	return ok
+}